@@ -0,0 +1,599 @@
+package gounit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// The assertions in this file complement those provided by
+// `github.com/smartystreets/assertions`. They share the same signature
+// (see the `so` parameter of Fixture.So) and are exported via the
+// variable block at the bottom of gounit.go.
+
+const (
+	success = ""
+
+	needExactValues = "This assertion requires exactly %d comparison values (you provided %d)."
+
+	shouldBeNumber            = "You must provide numeric values to this assertion (you provided '%v' and '%v')."
+	shouldUseNumericOperator  = "The comparison operator must be one of \"==\", \"<\", \">\", \"<=\", or \">=\" (you provided '%v')."
+	shouldHaveBeenNumerically = "Expected '%v' to be %s '%v' (compared numerically as %g %s %g, but it wasn't)!"
+
+	shouldHaveHadKeys = "Expected the map to have exactly the keys %v (missing: %v, extra: %v)!"
+
+	shouldHaveBeenAReader      = "You must provide io.Reader values to this assertion (you provided '%v' and '%v')."
+	shouldHaveReadWithoutError = "Expected the %s reader to be read without error (but got: '%v')!"
+	shouldHaveReadIdentically  = "Expected the readers to produce identical streams (but they differed at offset %d)!\nExpected: [% x]\nActual:   [% x]"
+
+	shouldHaveBeenTimes              = "You must provide a slice of time.Time values (you provided '%v')."
+	shouldHaveBeenStrictlyIncreasing = "Expected each time to be strictly after the previous one (but the time at index [%d] wasn't):\n  [%d]: %s\n  [%d]: %s"
+
+	shouldBothBeStrings              = "Both arguments to this assertion must be strings (you provided %v and %v)."
+	shouldHaveEqualedIgnoringCase    = "Expected: '%s'\nActual:   '%s'\n(Should be equal, ignoring case)"
+	shouldNotHaveEqualedIgnoringCase = "Expected     '%s'\nto NOT equal '%s' (ignoring case)\n(but it did)!"
+
+	shouldHaveBeenAMap                = "You must provide a map (you provided '%v')."
+	shouldUsePredicate                = "You must provide a predicate of type func(interface{}) bool (you provided '%v')."
+	shouldHaveSatisfiedPredicateValue = "Expected every value to satisfy the predicate (but the value at key '%v' didn't: '%v')!"
+	shouldHaveSatisfiedPredicateKey   = "Expected every key to satisfy the predicate (but the key '%v' didn't)!"
+
+	shouldHaveBeenARing       = "You must provide the ring's contents as a []interface{} (you provided '%v')."
+	shouldHaveContainedInRing = "Expected the ring (oldest to newest: %v) to contain '%v' (but it didn't)!"
+
+	shouldHaveSetEnvironmentVariable = "Expected the environment variable '%s' to be set (but it wasn't)!"
+	shouldHaveParsedEnvironmentValue = "Expected the environment variable '%s' ('%s') to be parsed as %v (but it couldn't be: %v)!"
+
+	shouldBothBeSlices     = "Both arguments to this assertion must be slices or arrays (you provided '%v' and '%v')."
+	shouldHaveHadPrefix    = "Expected %v\nto have the prefix %v\n(but they differed at index [%d])!"
+	shouldHaveBeenPrefixOf = "Expected %v\nto be a prefix of %v\n(but they differed at index [%d])!"
+
+	shouldUseBudget            = "You must provide a func(), a maximum time.Duration, and a maximum number of allocations (float64) to this assertion."
+	shouldHaveBeenWithinBudget = "Expected func() to complete within %v and %v allocations (but it took %v and made %v allocations)!"
+
+	shouldHaveBeenAChannel     = "You must provide a channel (you provided '%v')."
+	shouldHaveHadChannelLength = "Expected the channel to hold %v buffered values (but it held %d)!"
+
+	shouldBeNumbers               = "You must provide numeric values to this assertion (you provided '%v', '%v', and '%v')."
+	shouldHaveBeenStrictlyBetween = "Expected '%v' to be strictly between '%v' and '%v' (exclusive of both bounds, but it wasn't)!"
+	shouldHaveBeenInRange         = "Expected '%v' to be in the range ['%v', '%v'] (inclusive of both bounds, but it wasn't)!"
+
+	shouldUseStages = "You must provide the pipeline stages as a []func(interface{}) interface{} (you provided '%v')."
+
+	shouldUseConsumer              = "You must provide an io.Reader and a func(io.Reader) error (you provided '%v' and '%v')."
+	shouldHaveConsumedWithoutError = "Expected the reader to be consumed without error (but got: '%v')!"
+	shouldHaveConsumedAll          = "Expected the reader to be fully consumed (but %d bytes remained)!"
+
+	shouldBothBeTimes         = "Both arguments to this assertion must be time.Time values (you provided '%v' and '%v')."
+	shouldHaveBeenSameInstant = "Expected: '%s'\nActual:   '%s'\n(Should be the same instant)"
+
+	shouldUseSortable = "You must provide two []interface{} values and a func(a, b interface{}) bool (you provided '%v', '%v', and '%v')."
+
+	shouldUseStress                   = "You must provide a func(id int) and the number of goroutines and iterations (ints) (you provided '%v', '%v', and '%v')."
+	shouldNotHavePanickedConcurrently = "Expected no worker to panic (but %d panicked, the first (worker %d) with: '%v')!"
+
+	shouldHaveBeenAnError      = "You must provide error values to this assertion (you provided '%v')."
+	shouldHaveWrappedSentinels = "Expected the error ('%v') to wrap every sentinel (but it didn't wrap: %v)!"
+
+	shouldBeFloat        = "You must provide a numeric value to this assertion (you provided '%v')."
+	shouldUseSign        = "The sign must be an int: 1 (+Inf), -1 (-Inf), or 0 (either) (you provided '%v')."
+	shouldHaveBeenNaN    = "Expected '%v' to be NaN (but it wasn't)!"
+	shouldHaveBeenInf    = "Expected '%v' to be %s (but it wasn't)!"
+	shouldHaveBeenFinite = "Expected '%v' to be finite (but it wasn't)!"
+
+	shouldUseJSONPath            = "You must provide JSON ([]byte), a path (string), and an assertion func (you provided '%v', '%v', and '%v')."
+	shouldHaveBeenValidJSON      = "Expected valid JSON (but it wasn't: %v)!\nDocument: %s"
+	shouldHaveFoundJSONPath      = "Expected the path '%s' to be present in the document (but %v)!\nDocument: %s"
+	shouldSatisfyJSONPathFailure = "%s\n\nPath:     %s\nDocument: %s"
+
+	shouldUseMatrices            = "You must provide two [][]float64 matrices and a float64 tolerance (you provided '%v', '%v', and '%v')."
+	shouldHaveMatchingDimensions = "Expected the matrices to have matching dimensions (but %s)!"
+	shouldHaveBeenCloseAt        = "Expected the matrices to match within %v (but at (row %d, col %d) the actual value was %v and the expected value was %v)!"
+
+	shouldBeNumericSlice = "You must provide a slice of numeric values (you provided '%v')."
+	shouldHaveBeenAHeap  = "Expected a valid %s-heap (but the element at index [%d] ('%v') is %s than its child at index [%d] ('%v'))!"
+
+	shouldBeErrors      = "Both values must be errors (you provided '%v' and '%v')."
+	shouldHaveBeenError = "Expected error: '%v'\nActual error:   '%v'\n(Should be equal by identity or message)!"
+
+	shouldUseIdempotentFunc  = "You must provide a func() interface{} and a positive number of calls (you provided '%v' and '%v')."
+	shouldHaveBeenIdempotent = "Expected every call to produce the same result (but call #%d of %d diverged from call #1)!\nCall #1:  %#v\nCall #%d: %#v"
+
+	shouldUseSamples           = "You must provide a float64 value, a non-empty []float64 of samples, and a float64 threshold (you provided '%v', '%v', and '%v')."
+	shouldHaveBeenWithinStdDev = "Expected '%v' to be within %v standard deviations of the mean (but its z-score was %.3f; mean: %v, standard deviation: %v)!"
+
+	shouldUseKeyFunc       = "You must provide a slice and a func(interface{}) interface{} (you provided '%v' and '%v')."
+	shouldHaveBeenDistinct = "Expected all keys to be distinct (but key '%v' was shared by the elements at indexes [%d] and [%d])!"
+
+	shouldUseMessages          = "You must provide two messages of the same type (you provided '%v' and '%v')."
+	shouldHaveResembledMessage = "Expected the messages to resemble each other, ignoring unknown fields and default values (but these fields differed):\n%s"
+
+	shouldUseTemplate          = "You must provide a string, a template string, and the template's data (you provided '%v' and '%v')."
+	shouldHaveRenderedTemplate = "Could not render the template: %s"
+	shouldHaveMatchedTemplate  = "Expected the text to match the rendered template (but it didn't)!\nDiff (- expected, + actual):\n%s"
+
+	shouldUseRateChannel      = "You must provide a <-chan time.Time, a float64 rate, and a time.Duration (you provided '%v', '%v', and '%v')."
+	shouldHaveBeenRateLimited = "Expected at most %v events per second (but observed %d events in %v, a rate of %.2f per second)!"
+
+	shouldUseImages              = "You must provide two images and a uint8 tolerance (you provided '%v', '%v', and '%v')."
+	shouldHaveMatchingImageSizes = "Expected images of the same size (but actual was %dx%d and expected was %dx%d)!"
+	shouldHaveBeenCloseImages    = "Expected every channel of every pixel to be within %d (but the pixel at (%d, %d) differed)!\nExpected: RGBA%v\nActual:   RGBA%v"
+
+	shouldUseRetries    = "You must provide a func() error, a positive number of attempts, and a time.Duration (you provided '%v', '%v', and '%v')."
+	shouldHaveConverged = "Expected the function to eventually return nil (but it still failed after %d attempts)!\nLast error: %v"
+
+	shouldUseMutator     = "You must provide a func(interface{}) (you provided '%v')."
+	shouldNotHaveMutated = "Expected the input to be unchanged (but it was modified)!\n%s"
+
+	shouldUseNamedEvents     = "You must provide a map[string]time.Time and the names of two events in it (you provided '%v', '%v', and '%v')."
+	shouldHaveHappenedBefore = "Expected '%s' to happen before '%s' (but it happened %v later)!\n%s: %s\n%s: %s"
+
+	shouldBeInteger          = "You must provide integer values (you provided '%v')."
+	shouldUseNonZeroDivisor  = "You must provide a non-zero divisor (you provided '%v')."
+	shouldHaveBeenPowerOfTwo = "Expected '%v' to be a power of two (but it wasn't)!"
+	shouldHaveBeenDivisible  = "Expected '%v' to be divisible by '%v' (but the remainder was '%v')!"
+	shouldHaveBeenMultiple   = "Expected '%v' to be a multiple of '%v' (but it wasn't)!"
+
+	shouldHaveRoundTripped   = "Could not round-trip the value through JSON: %s"
+	shouldHaveBeenStableJSON = "Expected the JSON to be identical after a round-trip (but it differed at byte %d)!\nFirst:  %s\nSecond: %s"
+
+	shouldUseAccumulator         = "You must provide an increment func(), positive worker and per-worker counts, a read func() int, and an expected int (you provided '%v', '%v', '%v', '%v', and '%v')."
+	shouldHaveSummedConcurrently = "Expected a total of '%d' after %d workers each incremented %d times (but read '%d', a difference of %d)!"
+
+	shouldUseParser     = "You must provide a string and a func(string) (interface{}, error) (you provided '%v' and '%v')."
+	shouldHaveParsed    = "Expected the input to parse (but it didn't)!\nInput: %q\nError: %v"
+	shouldNotHaveParsed = "Expected the input to be rejected by the parser (but it parsed)!\nInput:  %q\nResult: %#v"
+
+	shouldUseCounter      = "You must provide a read func() int64, a func() to run, and an int64 delta (you provided '%v', '%v', and '%v')."
+	shouldHaveIncreasedBy = "Expected the counter to increase by '%d' (but it went from '%d' to '%d', a delta of '%d')!"
+
+	shouldUseIntervals      = "You must provide a [][2]int64 of intervals (you provided '%v')."
+	shouldNotHaveOverlapped = "Expected no intervals to overlap (but %v and %v did)!"
+
+	shouldUseCallSequence       = "You must provide a *CallSequence (you provided '%v')."
+	shouldHaveBeenCalledInOrder = "Expected the calls %q in that order (but '%s' was not recorded after %q)!\nRecorded: %q"
+
+	shouldUseDelays = "You must provide a []time.Duration of delays (you provided '%v')."
+	shouldHaveGrown = "Expected each delay to be greater than the previous one (but delay [%d] (%v) was not greater than delay [%d] (%v))!"
+
+	shouldUseSource       = "You must provide Go source code as a string (you provided '%v')."
+	shouldHaveBeenValidGo = "Expected valid Go source (but it didn't parse)!\n%s"
+
+	shouldUseCopyFunc      = "You must provide a func(interface{}) interface{} (you provided '%v')."
+	shouldHaveCopied       = "Expected the copy to resemble the original (but it didn't)!\n%s"
+	shouldHaveCopiedDeeply = "Expected the copy to be unaffected by changes to the original (but it shares memory with the original)!\n%s"
+
+	shouldUseStream                  = "You must provide a func() (interface{}, bool) and a predicate of type func(interface{}) bool (you provided '%v' and '%v')."
+	shouldHaveSatisfiedPredicateItem = "Expected every item of the stream to satisfy the predicate (but item [%d] didn't: '%v')!"
+
+	shouldHaveEventuallyPassed = "Expected the assertion to pass within %v (but it still failed after %d attempts)!\nLast result:\n%s"
+)
+
+func need(needed int, expected []interface{}) string {
+	if len(expected) != needed {
+		return fmt.Sprintf(needExactValues, needed, len(expected))
+	}
+	return success
+}
+
+// shouldBeNumerically receives exactly three parameters: an actual value,
+// a comparison operator ("==", "<", ">", "<=", ">="), and an expected value.
+// Both values are converted to float64 before comparison so that values of
+// differing numeric types (like an int and a float64) may be compared.
+func shouldBeNumerically(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	a, aOK := toFloat(actual)
+	b, bOK := toFloat(expected[1])
+	if !aOK || !bOK {
+		return fmt.Sprintf(shouldBeNumber, actual, expected[1])
+	}
+
+	var ok bool
+	switch op, _ := expected[0].(string); op {
+	case "==":
+		ok = a == b
+	case "<":
+		ok = a < b
+	case ">":
+		ok = a > b
+	case "<=":
+		ok = a <= b
+	case ">=":
+		ok = a >= b
+	default:
+		return fmt.Sprintf(shouldUseNumericOperator, expected[0])
+	}
+	if !ok {
+		return fmt.Sprintf(shouldHaveBeenNumerically, actual, expected[0], expected[1], a, expected[0], b)
+	}
+	return success
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// shouldHaveKeys receives a map and any number of expected keys and ensures
+// that the keys of the map are exactly the expected keys (no more, no less).
+func shouldHaveKeys(actual interface{}, expected ...interface{}) string {
+	m := reflect.ValueOf(actual)
+	if m.Kind() != reflect.Map {
+		return fmt.Sprintf(shouldHaveBeenAMap, actual)
+	}
+
+	var keys []interface{}
+	for _, key := range sortedKeys(m) {
+		keys = append(keys, key.Interface())
+	}
+	missing := difference(expected, keys)
+	extra := difference(keys, expected)
+	if len(missing) > 0 || len(extra) > 0 {
+		return fmt.Sprintf(shouldHaveHadKeys, expected, missing, extra)
+	}
+	return success
+}
+
+// difference returns the items of a that are not found in b.
+func difference(a, b []interface{}) (diff []interface{}) {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if reflect.DeepEqual(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, x)
+		}
+	}
+	return diff
+}
+
+// shouldEqualIgnoringCase receives exactly two strings and ensures that they
+// are equal under Unicode case-folding (see strings.EqualFold).
+func shouldEqualIgnoringCase(actual interface{}, expected ...interface{}) string {
+	a, b, fail := bothStrings(actual, expected)
+	if fail != success {
+		return fail
+	} else if !strings.EqualFold(a, b) {
+		return fmt.Sprintf(shouldHaveEqualedIgnoringCase, b, a)
+	}
+	return success
+}
+
+// shouldNotEqualIgnoringCase receives exactly two strings and ensures that
+// they are NOT equal under Unicode case-folding (see strings.EqualFold).
+func shouldNotEqualIgnoringCase(actual interface{}, expected ...interface{}) string {
+	a, b, fail := bothStrings(actual, expected)
+	if fail != success {
+		return fail
+	} else if strings.EqualFold(a, b) {
+		return fmt.Sprintf(shouldNotHaveEqualedIgnoringCase, a, b)
+	}
+	return success
+}
+
+func bothStrings(actual interface{}, expected []interface{}) (string, string, string) {
+	if fail := need(1, expected); fail != success {
+		return "", "", fail
+	}
+	a, aOK := actual.(string)
+	b, bOK := expected[0].(string)
+	if !aOK || !bOK {
+		return "", "", fmt.Sprintf(shouldBothBeStrings, reflect.TypeOf(actual), reflect.TypeOf(expected[0]))
+	}
+	return a, b, success
+}
+
+// shouldHavePrefix receives exactly two slices and ensures that the elements
+// of the second are the leading elements of the first.
+func shouldHavePrefix(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	if index, ok, fail := slicePrefix(actual, expected[0]); fail != success {
+		return fail
+	} else if !ok {
+		return fmt.Sprintf(shouldHaveHadPrefix, actual, expected[0], index)
+	}
+	return success
+}
+
+// shouldBePrefixOf receives exactly two slices and ensures that the elements
+// of the first are the leading elements of the second.
+func shouldBePrefixOf(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	if index, ok, fail := slicePrefix(expected[0], actual); fail != success {
+		return fail
+	} else if !ok {
+		return fmt.Sprintf(shouldHaveBeenPrefixOf, actual, expected[0], index)
+	}
+	return success
+}
+
+// slicePrefix reports whether prefix is a prefix of whole and, if not, the
+// index of the first mismatch.
+func slicePrefix(whole, prefix interface{}) (int, bool, string) {
+	w, p := reflect.ValueOf(whole), reflect.ValueOf(prefix)
+	if !isSlice(w) || !isSlice(p) {
+		return 0, false, fmt.Sprintf(shouldBothBeSlices, whole, prefix)
+	}
+	for i := 0; i < p.Len(); i++ {
+		if i >= w.Len() || !reflect.DeepEqual(w.Index(i).Interface(), p.Index(i).Interface()) {
+			return i, false, success
+		}
+	}
+	return 0, true, success
+}
+
+func isSlice(value reflect.Value) bool {
+	return value.Kind() == reflect.Slice || value.Kind() == reflect.Array
+}
+
+// shouldBeStrictlyBetween receives exactly three numeric parameters: an actual
+// value, a lower bound, and an upper bound. It ensures that the actual value
+// is greater than the lower bound and less than the upper bound.
+func shouldBeStrictlyBetween(actual interface{}, expected ...interface{}) string {
+	value, lower, upper, fail := numericBounds(actual, expected)
+	if fail != success {
+		return fail
+	} else if !(lower < value && value < upper) {
+		return fmt.Sprintf(shouldHaveBeenStrictlyBetween, actual, expected[0], expected[1])
+	}
+	return success
+}
+
+// shouldBeInRange receives exactly three numeric parameters: an actual value,
+// a lower bound, and an upper bound. It ensures that the actual value is
+// greater than or equal to the lower bound and less than or equal to the
+// upper bound.
+func shouldBeInRange(actual interface{}, expected ...interface{}) string {
+	value, lower, upper, fail := numericBounds(actual, expected)
+	if fail != success {
+		return fail
+	} else if !(lower <= value && value <= upper) {
+		return fmt.Sprintf(shouldHaveBeenInRange, actual, expected[0], expected[1])
+	}
+	return success
+}
+
+func numericBounds(actual interface{}, expected []interface{}) (value, lower, upper float64, fail string) {
+	if fail = need(2, expected); fail != success {
+		return 0, 0, 0, fail
+	}
+	value, valueOK := toFloat(actual)
+	lower, lowerOK := toFloat(expected[0])
+	upper, upperOK := toFloat(expected[1])
+	if !valueOK || !lowerOK || !upperOK {
+		return 0, 0, 0, fmt.Sprintf(shouldBeNumbers, actual, expected[0], expected[1])
+	}
+	return value, lower, upper, success
+}
+
+// shouldBeSameInstant receives exactly two time.Time values and ensures that
+// they represent the same instant (see time.Time.Equal), regardless of
+// location or monotonic clock readings.
+func shouldBeSameInstant(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	a, aOK := actual.(time.Time)
+	b, bOK := expected[0].(time.Time)
+	if !aOK || !bOK {
+		return fmt.Sprintf(shouldBothBeTimes, actual, expected[0])
+	} else if !a.Equal(b) {
+		return fmt.Sprintf(shouldHaveBeenSameInstant, b.Format(time.RFC3339Nano), a.Format(time.RFC3339Nano))
+	}
+	return success
+}
+
+// shouldBeNaN receives a single numeric parameter and ensures that it is NaN
+// ("not a number"), which can't be verified with ShouldEqual (NaN != NaN).
+func shouldBeNaN(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	value, ok := toFloat(actual)
+	if !ok {
+		return fmt.Sprintf(shouldBeFloat, actual)
+	} else if !math.IsNaN(value) {
+		return fmt.Sprintf(shouldHaveBeenNaN, actual)
+	}
+	return success
+}
+
+// shouldBeInf receives a numeric parameter and a sign and ensures that the
+// value is an infinity: +Inf if sign > 0, -Inf if sign < 0, or either if
+// sign == 0 (see math.IsInf).
+func shouldBeInf(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	value, ok := toFloat(actual)
+	if !ok {
+		return fmt.Sprintf(shouldBeFloat, actual)
+	}
+	sign, ok := expected[0].(int)
+	if !ok {
+		return fmt.Sprintf(shouldUseSign, expected[0])
+	}
+	if !math.IsInf(value, sign) {
+		infinity := "±Inf"
+		if sign > 0 {
+			infinity = "+Inf"
+		} else if sign < 0 {
+			infinity = "-Inf"
+		}
+		return fmt.Sprintf(shouldHaveBeenInf, actual, infinity)
+	}
+	return success
+}
+
+// shouldBeFinite receives a single numeric parameter and ensures that it is
+// neither NaN nor an infinity.
+func shouldBeFinite(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	value, ok := toFloat(actual)
+	if !ok {
+		return fmt.Sprintf(shouldBeFloat, actual)
+	} else if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Sprintf(shouldHaveBeenFinite, actual)
+	}
+	return success
+}
+
+// shouldBeMinHeap receives a slice of numbers and ensures that it satisfies
+// the min-heap property: each element (at index i) is less than or equal to
+// its children (at indexes 2i+1 and 2i+2).
+func shouldBeMinHeap(actual interface{}, expected ...interface{}) string {
+	return shouldBeHeap(actual, expected, "min", "greater", func(parent, child float64) bool { return parent <= child })
+}
+
+// shouldBeMaxHeap receives a slice of numbers and ensures that it satisfies
+// the max-heap property: each element (at index i) is greater than or equal
+// to its children (at indexes 2i+1 and 2i+2).
+func shouldBeMaxHeap(actual interface{}, expected ...interface{}) string {
+	return shouldBeHeap(actual, expected, "max", "less", func(parent, child float64) bool { return parent >= child })
+}
+
+func shouldBeHeap(actual interface{}, expected []interface{}, kind, violation string, ordered func(parent, child float64) bool) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	heap := reflect.ValueOf(actual)
+	if !isSlice(heap) {
+		return fmt.Sprintf(shouldBeNumericSlice, actual)
+	}
+	values := make([]float64, heap.Len())
+	for i := range values {
+		value, ok := toFloat(heap.Index(i).Interface())
+		if !ok {
+			return fmt.Sprintf(shouldBeNumericSlice, actual)
+		}
+		values[i] = value
+	}
+	for child := 1; child < len(values); child++ {
+		parent := (child - 1) / 2
+		if !ordered(values[parent], values[child]) {
+			return fmt.Sprintf(shouldHaveBeenAHeap, kind, parent, heap.Index(parent), violation, child, heap.Index(child))
+		}
+	}
+	return success
+}
+
+// shouldBeError receives exactly two errors and ensures that they are equal
+// by value: either the first matches the second according to errors.Is, or
+// both errors have the same message.
+func shouldBeError(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	if actual == nil && expected[0] == nil {
+		return success
+	}
+	actualError, actualOK := actual.(error)
+	expectedError, expectedOK := expected[0].(error)
+	if (actual != nil && !actualOK) || (expected[0] != nil && !expectedOK) {
+		return fmt.Sprintf(shouldBeErrors, actual, expected[0])
+	}
+	if actualError == nil || expectedError == nil {
+		return fmt.Sprintf(shouldHaveBeenError, expectedError, actualError)
+	}
+	if errors.Is(actualError, expectedError) || actualError.Error() == expectedError.Error() {
+		return success
+	}
+	return fmt.Sprintf(shouldHaveBeenError, expectedError, actualError)
+}
+
+// toInteger converts any of Go's integer types to a big.Int (so that the
+// full range of uint64 values is supported).
+func toInteger(value interface{}) (*big.Int, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(v.Uint()), true
+	}
+	return nil, false
+}
+
+// shouldBePowerOfTwo receives a single integer and ensures that it is a
+// (positive) power of two.
+func shouldBePowerOfTwo(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	value, ok := toInteger(actual)
+	if !ok {
+		return fmt.Sprintf(shouldBeInteger, actual)
+	}
+	if value.Sign() <= 0 || value.BitLen()-1 != int(value.TrailingZeroBits()) {
+		return fmt.Sprintf(shouldHaveBeenPowerOfTwo, actual)
+	}
+	return success
+}
+
+// shouldBeDivisibleBy receives exactly two integers and ensures that the
+// first is divisible by the second (with no remainder).
+func shouldBeDivisibleBy(actual interface{}, expected ...interface{}) string {
+	remainder, fail := remainderOf(actual, expected)
+	if fail != success {
+		return fail
+	}
+	if remainder.Sign() != 0 {
+		return fmt.Sprintf(shouldHaveBeenDivisible, actual, expected[0], remainder)
+	}
+	return success
+}
+
+// shouldBeMultipleOf receives exactly two integers and ensures that the
+// first is a multiple of the second.
+func shouldBeMultipleOf(actual interface{}, expected ...interface{}) string {
+	remainder, fail := remainderOf(actual, expected)
+	if fail != success {
+		return fail
+	}
+	if remainder.Sign() != 0 {
+		return fmt.Sprintf(shouldHaveBeenMultiple, actual, expected[0])
+	}
+	return success
+}
+
+func remainderOf(actual interface{}, expected []interface{}) (*big.Int, string) {
+	if fail := need(1, expected); fail != success {
+		return nil, fail
+	}
+	dividend, ok := toInteger(actual)
+	if !ok {
+		return nil, fmt.Sprintf(shouldBeInteger, actual)
+	}
+	divisor, ok := toInteger(expected[0])
+	if !ok {
+		return nil, fmt.Sprintf(shouldBeInteger, expected[0])
+	}
+	if divisor.Sign() == 0 {
+		return nil, fmt.Sprintf(shouldUseNonZeroDivisor, expected[0])
+	}
+	return new(big.Int).Rem(dividend, divisor), success
+}
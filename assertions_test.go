@@ -0,0 +1,257 @@
+package gounit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestShouldBeNumerically(t *testing.T) {
+	passing := [][]interface{}{
+		{1, "==", 1.0},
+		{1, "<", 1.5},
+		{2, ">", 1.5},
+		{1, "<=", 1.0},
+		{uint8(2), ">=", 1.5},
+		{float32(0.5), "<", int64(1)},
+	}
+	for _, c := range passing {
+		if ok, message := So(c[0], ShouldBeNumerically, c[1], c[2]); !ok {
+			t.Error("\n" + message)
+		}
+	}
+
+	failing := [][]interface{}{
+		{1, "==", 1.5},
+		{2, "<", 1.5},
+		{1, ">", 1.5},
+		{2, "<=", 1.5},
+		{1, ">=", 1.5},
+		{1, "!=", 1.5},
+		{"1", "==", 1.0},
+	}
+	for _, c := range failing {
+		if ok, message := So(ShouldBeNumerically(c[0], c[1], c[2]), ShouldNotBeBlank); !ok {
+			t.Errorf("\n%v %v %v: %s", c[0], c[1], c[2], message)
+		}
+	}
+}
+
+func TestShouldHaveKeys(t *testing.T) {
+	config := map[string]int{"host": 1, "port": 2}
+
+	if ok, message := So(config, ShouldHaveKeys, "port", "host"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldHaveKeys(config, "host"), ShouldContainSubstring, "extra: [port]"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldHaveKeys(config, "host", "port", "user"), ShouldContainSubstring, "missing: [user]"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldHaveKeys("not a map", "host"), ShouldNotBeBlank); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldEqualIgnoringCase(t *testing.T) {
+	if ok, message := So("Hello, World", ShouldEqualIgnoringCase, "hELLO, wORLD"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldEqualIgnoringCase("Hello", "Goodbye"), ShouldContainSubstring, "Goodbye"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldEqualIgnoringCase(1, "1"), ShouldNotBeBlank); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldNotEqualIgnoringCase(t *testing.T) {
+	if ok, message := So("Hello", ShouldNotEqualIgnoringCase, "Goodbye"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldNotEqualIgnoringCase("Hello", "hELLO"), ShouldNotBeBlank); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldHavePrefix(t *testing.T) {
+	if ok, message := So([]int{1, 2, 3}, ShouldHavePrefix, []int{1, 2}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldHavePrefix([]int{1, 2, 3}, []int{1, 3}), ShouldContainSubstring, "index [1]"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldHavePrefix([]int{1}, []int{1, 2}), ShouldContainSubstring, "index [1]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBePrefixOf(t *testing.T) {
+	if ok, message := So([]string{"a", "b"}, ShouldBePrefixOf, []string{"a", "b", "c"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBePrefixOf([]string{"b"}, []string{"a", "b"}), ShouldContainSubstring, "index [0]"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBePrefixOf("a", []string{"a"}), ShouldNotBeBlank); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBeStrictlyBetween(t *testing.T) {
+	if ok, message := So(5, ShouldBeStrictlyBetween, 4, 6); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(4.5, ShouldBeStrictlyBetween, 4, 5); !ok {
+		t.Error("\n" + message)
+	}
+	for _, boundary := range []int{4, 6} {
+		if ok, message := So(ShouldBeStrictlyBetween(boundary, 4, 6), ShouldNotBeBlank); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+func TestShouldBeInRange(t *testing.T) {
+	for _, value := range []interface{}{4, 5, 6, 5.5} {
+		if ok, message := So(value, ShouldBeInRange, 4, 6); !ok {
+			t.Error("\n" + message)
+		}
+	}
+	for _, value := range []interface{}{3, 7, 6.01} {
+		if ok, message := So(ShouldBeInRange(value, 4, 6), ShouldNotBeBlank); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+func TestShouldBeSameInstant(t *testing.T) {
+	now := time.Now()
+
+	if ok, message := So(now, ShouldBeSameInstant, now.Round(0)); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(now, ShouldBeSameInstant, now.UTC()); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeSameInstant(now, now.Add(time.Nanosecond)), ShouldNotBeBlank); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestNaNAndInf(t *testing.T) {
+	nan, positive, negative, finite := math.NaN(), math.Inf(1), math.Inf(-1), 42.0
+
+	if ok, message := So(nan, ShouldBeNaN); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(positive, ShouldBeInf, 1); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(negative, ShouldBeInf, -1); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(negative, ShouldBeInf, 0); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(finite, ShouldBeFinite); !ok {
+		t.Error("\n" + message)
+	}
+
+	failures := []string{
+		ShouldBeNaN(finite),
+		ShouldBeInf(positive, -1),
+		ShouldBeInf(negative, 1),
+		ShouldBeInf(finite, 0),
+		ShouldBeFinite(nan),
+		ShouldBeFinite(positive),
+	}
+	for _, failure := range failures {
+		if ok, message := So(failure, ShouldNotBeBlank); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+func TestShouldBeMinHeap(t *testing.T) {
+	if ok, message := So([]int{1, 3, 2, 7, 4, 5}, ShouldBeMinHeap); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeMinHeap([]int{1, 3, 2, 7, 0}), ShouldContainSubstring, "index [1] ('3') is greater than its child at index [4] ('0')"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBeMaxHeap(t *testing.T) {
+	if ok, message := So([]float64{9, 5, 8, 1, 4}, ShouldBeMaxHeap); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeMaxHeap([]float64{9, 5, 10}), ShouldContainSubstring, "index [0] ('9') is less than its child at index [2] ('10')"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBeError(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	if ok, message := So(sentinel, ShouldBeError, sentinel); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(fmt.Errorf("wrapped: %w", sentinel), ShouldBeError, sentinel); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(errors.New("sentinel"), ShouldBeError, sentinel); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeError(errors.New("other"), sentinel), ShouldContainSubstring, "Expected error: 'sentinel'\nActual error:   'other'"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeError(nil, sentinel), ShouldNotBeBlank); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBePowerOfTwo(t *testing.T) {
+	for _, value := range []interface{}{1, 2, 64, int8(64), uint64(1) << 63} {
+		if ok, message := So(value, ShouldBePowerOfTwo); !ok {
+			t.Error("\n" + message)
+		}
+	}
+	for _, value := range []interface{}{0, -2, 3, 96, uint64(1)<<63 + 1} {
+		if ok, message := So(ShouldBePowerOfTwo(value), ShouldContainSubstring, "to be a power of two"); !ok {
+			t.Error("\n" + message)
+		}
+	}
+	if ok, message := So(ShouldBePowerOfTwo(2.0), ShouldContainSubstring, "You must provide integer values"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBeDivisibleBy(t *testing.T) {
+	if ok, message := So(12, ShouldBeDivisibleBy, 4); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(uint16(4096), ShouldBeDivisibleBy, int64(-512)); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeDivisibleBy(13, 4), ShouldEqual, "Expected '13' to be divisible by '4' (but the remainder was '1')!"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeDivisibleBy(13, 0), ShouldContainSubstring, "non-zero divisor"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestShouldBeMultipleOf(t *testing.T) {
+	if ok, message := So(24, ShouldBeMultipleOf, 8); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(0, ShouldBeMultipleOf, 8); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ShouldBeMultipleOf(20, 8), ShouldEqual, "Expected '20' to be a multiple of '8' (but it wasn't)!"); !ok {
+		t.Error("\n" + message)
+	}
+}
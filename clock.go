@@ -0,0 +1,164 @@
+package gounit
+
+import (
+	"sync"
+	"time"
+)
+
+// clock is a fake clock that only moves when told to (see Advance).
+type clock struct {
+	lock   sync.Mutex
+	now    time.Time
+	alarms []*alarm
+}
+
+// alarm is a pending timer, ticker, or callback registered with a clock.
+type alarm struct {
+	deadline time.Time
+	period   time.Duration // period is non-zero for tickers.
+	channel  chan time.Time
+	callback func()
+	stopped  bool
+}
+
+// Timer is the fake-clock counterpart of time.Timer (see Fixture.NewTimer).
+type Timer struct {
+	C     <-chan time.Time
+	clock *clock
+	alarm *alarm
+}
+
+// Stop prevents the timer from firing. It returns false if the timer
+// had already fired or been stopped.
+func (self *Timer) Stop() bool {
+	return self.clock.stop(self.alarm)
+}
+
+// Ticker is the fake-clock counterpart of time.Ticker (see Fixture.NewTicker).
+type Ticker struct {
+	C     <-chan time.Time
+	clock *clock
+	alarm *alarm
+}
+
+// Stop turns off the ticker.
+func (self *Ticker) Stop() {
+	self.clock.stop(self.alarm)
+}
+
+// Now returns the current time according to the fixture's fake clock, which
+// starts at the (real) time it is first used in each test case and only
+// moves forward when Advance is called.
+func (self *Fixture) Now() time.Time {
+	clock := self.fakeClock()
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	return clock.now
+}
+
+// NewTimer creates a Timer that will send the fake time on its channel once
+// the fake clock has been advanced by at least d.
+func (self *Fixture) NewTimer(d time.Duration) *Timer {
+	clock := self.fakeClock()
+	alarm := clock.register(d, 0, nil)
+	return &Timer{C: alarm.channel, clock: clock, alarm: alarm}
+}
+
+// NewTicker creates a Ticker that sends the fake time on its channel each
+// time the fake clock passes another multiple of d. Like time.Ticker, ticks
+// are dropped if the receiver falls behind.
+func (self *Fixture) NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	clock := self.fakeClock()
+	alarm := clock.register(d, d, nil)
+	return &Ticker{C: alarm.channel, clock: clock, alarm: alarm}
+}
+
+// AfterFunc arranges for f to be called (by Advance, on the goroutine that
+// calls it) once the fake clock has been advanced by at least d.
+func (self *Fixture) AfterFunc(d time.Duration, f func()) *Timer {
+	clock := self.fakeClock()
+	alarm := clock.register(d, 0, f)
+	return &Timer{clock: clock, alarm: alarm}
+}
+
+// Advance moves the fixture's fake clock forward by d, firing (in order)
+// any timers, tickers, and callbacks that come due along the way.
+func (self *Fixture) Advance(d time.Duration) {
+	self.fakeClock().advance(d)
+}
+
+func (self *Fixture) fakeClock() *clock {
+	if self.clock == nil {
+		self.clock = &clock{now: time.Now()}
+	}
+	return self.clock
+}
+
+func (self *clock) register(d, period time.Duration, callback func()) *alarm {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	alarm := &alarm{
+		deadline: self.now.Add(d),
+		period:   period,
+		channel:  make(chan time.Time, 1),
+		callback: callback,
+	}
+	self.alarms = append(self.alarms, alarm)
+	return alarm
+}
+
+func (self *clock) stop(alarm *alarm) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	active := !alarm.stopped
+	alarm.stopped = true
+	return active
+}
+
+func (self *clock) advance(d time.Duration) {
+	self.lock.Lock()
+	target := self.now.Add(d)
+	for {
+		next := self.next(target)
+		if next == nil {
+			break
+		}
+		self.now = next.deadline
+		if next.period > 0 {
+			next.deadline = next.deadline.Add(next.period)
+		} else {
+			next.stopped = true
+		}
+		if next.callback != nil {
+			self.lock.Unlock() // the callback may well use the clock.
+			next.callback()
+			self.lock.Lock()
+			continue
+		}
+		select {
+		case next.channel <- self.now:
+		default:
+		}
+	}
+	self.now = target
+	self.lock.Unlock()
+}
+
+// next returns the earliest active alarm due at or before target (if any).
+func (self *clock) next(target time.Time) *alarm {
+	var earliest *alarm
+	for _, alarm := range self.alarms {
+		if alarm.stopped || alarm.deadline.After(target) {
+			continue
+		}
+		if earliest == nil || alarm.deadline.Before(earliest.deadline) {
+			earliest = alarm
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,54 @@
+package gounit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdvanceFiresTimerCallbacks(t *testing.T) {
+	fired := 0
+	spy := new(spyT)
+	f := NewFixture("Advance", spy)
+	f.Test("Timer callback", func() {
+		f.AfterFunc(time.Minute, func() { fired++ })
+		f.Advance(59 * time.Second)
+		if fired != 0 {
+			t.Error("The callback should not have fired before its deadline.")
+		}
+		f.Advance(time.Second)
+		f.Advance(time.Hour)
+	})
+	f.Run()
+
+	if ok, message := So(fired, ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAdvanceFiresTimersAndTickers(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("Advance", spy)
+	f.Test("Timers and tickers", func() {
+		start := f.Now()
+		timer := f.NewTimer(time.Second)
+		ticker := f.NewTicker(time.Second)
+		stopped := f.NewTimer(time.Second)
+		stopped.Stop()
+
+		f.Advance(1500 * time.Millisecond)
+
+		f.So("The timer fired at its deadline", <-timer.C, ShouldResemble, start.Add(time.Second))
+		f.So("The ticker ticked", <-ticker.C, ShouldResemble, start.Add(time.Second))
+		f.So("The stopped timer did not fire", len(stopped.C), ShouldEqual, 0)
+		f.So("The clock advanced", f.Now(), ShouldResemble, start.Add(1500*time.Millisecond))
+
+		f.Advance(time.Second)
+		f.So("The ticker ticked again", <-ticker.C, ShouldResemble, start.Add(2*time.Second))
+		f.So("The timer fired only once", len(timer.C), ShouldEqual, 0)
+	})
+	f.Run()
+
+	if spy.failed {
+		t.Error("Expected the fake clock to fire timers deterministically:\n" + f.output.String())
+	}
+}
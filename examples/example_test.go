@@ -45,3 +45,19 @@ func TestTable(t *testing.T) {
 		})
 	}
 }
+
+func TestGuardedAssertions(t *testing.T) {
+	type user struct{ Name string }
+	users := map[string]*user{"mike": {Name: "Mike"}}
+
+	f := NewFixture("Guarding against follow-on failures", t)
+	defer f.Run()
+
+	f.Test("A user should be found by id", func() {
+		found := users["mike"]
+		if !f.So("The user should be found", found, ShouldNotBeNil) {
+			return // rather than dereferencing nil below.
+		}
+		f.So("The user should have a name", found.Name, ShouldEqual, "Mike")
+	})
+}
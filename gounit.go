@@ -2,16 +2,27 @@
 //
 // http://en.wikipedia.org/wiki/XUnit
 //
-// (No attempt has yet been made to produce XUnit-style XML output.)
+// (See Fixture.WriteXML for XUnit-style XML output.)
 package gounit
 
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"testing"
+	"time"
 
 	"github.com/smartystreets/assertions"
 )
@@ -27,18 +38,101 @@ type T interface {
 
 // A simple xunit-style test fixture. Call NewFixture to create one.
 type Fixture struct {
-	t      T
-	waiter *sync.WaitGroup
+	t           T
+	description string
+	lock        sync.Mutex // lock guards the output and failure state (see Parallel).
+
+	filter    *regexp.Regexp      // filter (if set) must match the descriptions of test cases that run (see NewFixture).
+	failFast  bool                // failFast stops the run at the first failure (see FailFast).
+	stopped   map[string]struct{} // stopped are the test cases not run because of FailFast.
+	timeout   time.Duration       // timeout (if set) limits how long each test case may take (see Timeout).
+	semaphore chan struct{}       // semaphore (if set) limits the number of test cases running in parallel.
+	running   sync.WaitGroup      // running tracks the test cases running in parallel.
+	inFlight  map[uint64]*outcome // inFlight maps goroutines to the test cases they run in parallel.
 
 	frozen  bool // frozen prevents setup, teardown, and tests from being registered.
 	spoiled bool // spoiled marks the whole fixture as failed.
+	started bool // started records that Run began executing test cases.
+	failed  bool // failed records that the fixture has reported a failure.
+
+	setups    []func() // setups are run (in registration order) before each test case.
+	setupOnce func()   // setupOnce (if set) is run before the first test case only.
+	beforeAll func()   // beforeAll (if set) is run once, before all test cases.
+	afterAll  func()   // afterAll (if set) is run once, after all test cases.
+	teardown  func(passed bool)
 
-	setup    func()
-	teardown func()
+	beforeNamed func(description string)
+	afterNamed  func(description string)
 
 	tests   map[string]func(func())
+	order   []string // order lists the descriptions of tests in the order they were registered.
 	focused map[string]struct{}
-	skipped map[string]struct{}
+	skipped map[string]string // skipped maps descriptions to the reason for skipping (if any).
+	slow    map[string]struct{}
+
+	conditions map[string]func() bool // conditions are evaluated at Run (see TestWhen).
+
+	quarantined  map[string]struct{}
+	quarantining bool // quarantining marks the test case in progress as quarantined.
+
+	group      string            // group is the group being registered (or run) at the moment.
+	groups     map[string]string // groups maps descriptions to the group (if any) of each test case.
+	groupOrder []string          // groupOrder lists the groups in the order they were declared.
+
+	seed       int64
+	seedLogged bool
+	random     *rand.Rand // random is reset (re-seeded) before each test case.
+	clock      *clock     // clock is the fake clock (see Advance), reset before each test case.
+
+	shuffle     bool  // shuffle randomizes the order in which test cases run.
+	shuffleSeed int64 // shuffleSeed seeds the shuffling (see RandomizeOrder).
+
+	inline        bool // inline marks each So line with its outcome.
+	preview       bool // preview logs assertions instead of executing them.
+	requireOutput bool // requireOutput fails test cases that log nothing.
+	trackMemory   bool // trackMemory logs the heap growth of each test case.
+
+	heapLimit uint64 // heapLimit (if non-zero) is the allowed heap growth of each test case.
+
+	todo string // todo (if set) is the reason the whole fixture is pending.
+
+	onlyOn []string // onlyOn lists the only operating systems on which to run.
+	skipOn []string // skipOn lists operating systems on which not to run.
+
+	afterAssertion func(description string, passed bool)
+	panicHandler   func(recovered interface{}) (fail bool, message string)
+	sanitizer      func(description string) string
+
+	completed     []int // completed records ids in the order reported (see GoTestOrdered).
+	completedLock sync.Mutex
+
+	expectedLogs []string      // expectedLogs are substrings expected in the current test's log output.
+	capturedLogs *bytes.Buffer // capturedLogs holds standard log output (see ExpectLog).
+	restoreLog   io.Writer
+
+	faults      []fault // faults are injected, one at a time, into extra runs of each test case.
+	activeFault string  // activeFault names the fault injected into the test case in progress.
+
+	steps int // steps counts the steps taken by the current test case.
+
+	helpers     map[string]struct{} // helpers are the names of functions marked as helpers (see Helper).
+	stackTraces bool                // stackTraces causes full stack traces of panics to be logged.
+
+	expectedAssertions map[string]int // expectedAssertions are the exact counts expected of test cases.
+	warnUnasserted     bool           // warnUnasserted causes test cases without assertions to be warned about.
+	step               int            // step is the number of the step in progress (if any).
+
+	outcomes  []*outcome
+	elapsed   time.Duration            // elapsed is the time taken to run all test cases.
+	durations map[string]time.Duration // durations are those of each test case (see Durations).
+	dedupe    bool                     // dedupe groups identical failures in a summary.
+	warnings  int                      // warnings counts failed soft assertions (see Warn) and the like.
+
+	outputDir string   // outputDir (if set) receives a file with the output of each test case.
+	current   *outcome // current is the outcome of the test case in progress.
+
+	begin  func() (commit func(), rollback func()) // begin starts a transaction for each test case (see WithRollback).
+	commit func()                                  // commit commits the current transaction (if not yet finished).
 
 	output *bytes.Buffer
 }
@@ -47,21 +141,56 @@ type Fixture struct {
 // methods to register and run test cases and optional setup and teardown
 // functions. Because these methods return their receiver you have the option
 // to chain the method calls if you like that sort of thing (I know I do).
+// Set the GOUNIT_RUN environment variable to a regular expression to run
+// only the test cases with matching descriptions (like `go test -run`).
 func NewFixture(description string, t T) *Fixture {
-	return &Fixture{
-		t:      t,
-		waiter: new(sync.WaitGroup),
+	fixture := &Fixture{
+		t:           t,
+		description: description,
+
+		teardown: func(bool) {},
 
-		setup:    func() {},
-		teardown: func() {},
+		beforeNamed: func(string) {},
+		afterNamed:  func(string) {},
 
 		tests:   make(map[string]func(func())),
 		focused: make(map[string]struct{}),
-		skipped: make(map[string]struct{}),
+		skipped: make(map[string]string),
+		slow:    make(map[string]struct{}),
+
+		conditions:  make(map[string]func() bool),
+		quarantined: make(map[string]struct{}),
+		groups:      make(map[string]string),
+		durations:   make(map[string]time.Duration),
+		stopped:     make(map[string]struct{}),
+
+		expectedAssertions: make(map[string]int),
+
+		seed: time.Now().UnixNano(),
 
 		output:  bytes.NewBufferString(description + "\n"),
 		spoiled: len(description) == 0,
 	}
+	fixture.filterFromEnvironment()
+	return fixture
+}
+
+// filterFromEnvironment compiles the GOUNIT_RUN environment variable (if
+// set), a regular expression that test case descriptions must match in
+// order to run (like `go test -run`). An invalid expression spoils the
+// fixture.
+func (self *Fixture) filterFromEnvironment() {
+	pattern := os.Getenv("GOUNIT_RUN")
+	if pattern == "" {
+		return
+	}
+	filter, err := regexp.Compile(pattern)
+	if err != nil {
+		self.spoiled = true
+		self.Logf("Invalid GOUNIT_RUN pattern: %s\n", err)
+		return
+	}
+	self.filter = filter
 }
 
 func SkipNewFixture(description string, t T) *Fixture {
@@ -73,24 +202,102 @@ func SkipNewFixture(description string, t T) *Fixture {
 
 // Setup registers a function to be run before any and all test cases.
 // Subsequent calls to this function overwrite the previously registered
-// setup function.
+// setup function (including any added with AddSetup).
 func (self *Fixture) Setup(action func()) {
 	if self.frozen {
 		return
 	}
-	self.setup = action
+	self.setups = []func(){action}
+}
+
+// AddSetup registers an additional function to be run before any and all
+// test cases. Unlike Setup, it doesn't overwrite the functions registered
+// previously; all of them are run in the order they were registered.
+func (self *Fixture) AddSetup(action func()) {
+	if self.frozen {
+		return
+	}
+	self.setups = append(self.setups, action)
+}
+
+// SetupOnce registers a function to be run before the first test case only
+// (before any registered setup function). Unlike Setup, it is not repeated
+// for subsequent test cases, though any registered Teardown still runs
+// after each of them. Subsequent calls to this function overwrite the
+// previously registered function.
+func (self *Fixture) SetupOnce(action func()) {
+	if self.frozen {
+		return
+	}
+	self.setupOnce = action
+}
+
+// BeforeAll registers a function to be run once, before the first test case
+// (and its setup), for preparing resources shared by all test cases. If it
+// panics, the fixture fails and none of the test cases are run. Subsequent
+// calls to this function overwrite the previously registered function.
+func (self *Fixture) BeforeAll(action func()) {
+	if self.frozen {
+		return
+	}
+	self.beforeAll = action
+}
+
+// AfterAll registers a function to be run once, after the last test case
+// (and its teardown), even when test cases panic or BeforeAll fails.
+// Subsequent calls to this function overwrite the previously registered
+// function.
+func (self *Fixture) AfterAll(action func()) {
+	if self.frozen {
+		return
+	}
+	self.afterAll = action
 }
 
 // Teardown registers a function to be run after any and all test cases,
 // even when test cases panic. Subsequent calls to this function
 // overwrite the previously registered teardown function.
 func (self *Fixture) Teardown(action func()) {
+	if self.frozen {
+		return
+	}
+	self.teardown = func(bool) { action() }
+}
+
+// TeardownWithResult registers a teardown function (see Teardown) that
+// receives whether the test case that just ran passed (even when running in
+// parallel; see Parallel), which is handy for dumping extra diagnostics only
+// when a test case fails. It replaces any previously registered teardown
+// function.
+func (self *Fixture) TeardownWithResult(action func(passed bool)) {
 	if self.frozen {
 		return
 	}
 	self.teardown = action
 }
 
+// BeforeEachNamed registers a function to be run (after any registered
+// setup) before each test case, receiving the description of the test case.
+// Subsequent calls to this function overwrite the previously registered
+// function.
+func (self *Fixture) BeforeEachNamed(action func(description string)) {
+	if self.frozen {
+		return
+	}
+	self.beforeNamed = action
+}
+
+// AfterEachNamed registers a function to be run (before any registered
+// teardown) after each test case, receiving the description of the test
+// case. Subsequent calls to this function overwrite the previously
+// registered function.
+func (self *Fixture) AfterEachNamed(action func(description string)) {
+	if self.frozen {
+		return
+	}
+	self.afterNamed = action
+}
+
 // Test registers a test case, to be run after any registered setup and
 // before any registered teardown. Test cases must have unique descriptions
 // within the context of a Fixture.
@@ -98,7 +305,7 @@ func (self *Fixture) Test(description string, action func()) {
 	if self.frozen {
 		return
 	}
-	self.validate(description)
+	self.register(description)
 
 	self.tests[description] = func(done func()) {
 		defer done()
@@ -114,9 +321,19 @@ func (self *Fixture) SkipTest(description string, action func()) {
 	if self.frozen {
 		return
 	}
-	self.validate(description)
+	self.register(description)
 	self.tests[description] = nil
-	self.skipped[description] = struct{}{}
+	self.skipped[description] = ""
+}
+
+// SkipTestReason is like SkipTest but records why the test case is skipped,
+// which is logged along with it (and in the summary of skipped tests).
+func (self *Fixture) SkipTestReason(description, reason string, action func()) {
+	if self.frozen {
+		return
+	}
+	self.SkipTest(description, action)
+	self.skipped[description] = reason
 }
 
 // FocusTest registers a test to be run instead of any other tests not
@@ -127,11 +344,62 @@ func (self *Fixture) FocusTest(description string, action func()) {
 	if self.frozen {
 		return
 	}
-	self.validate(description)
 	self.focused[description] = struct{}{}
 	self.Test(description, action)
 }
 
+// SlowTest registers a test case like Test, but marks it as slow. Slow
+// test cases are skipped when running in -short mode (see testing.Short).
+func (self *Fixture) SlowTest(description string, action func()) {
+	if self.frozen {
+		return
+	}
+	self.slow[description] = struct{}{}
+	self.Test(description, action)
+}
+
+// Quarantine registers a (known-flaky) test case like Test, but a failure
+// of the test case doesn't fail the fixture. The test case still runs and
+// its outcome is still logged and recorded, unlike with SkipTest.
+func (self *Fixture) Quarantine(description string, action func()) {
+	if self.frozen {
+		return
+	}
+	self.quarantined[description] = struct{}{}
+	self.Test(description, action)
+}
+
+// TestWhen registers a test case like Test, but the condition is evaluated
+// at Run and the test case only runs if the condition is true. Otherwise it
+// is logged as skipped (along with the reason).
+func (self *Fixture) TestWhen(condition func() bool, description string, action func()) {
+	if self.frozen {
+		return
+	}
+	self.Test(description, action)
+	self.conditions[description] = condition
+}
+
+// Group tags all test cases registered by fn with the group name. When run,
+// grouped test cases are rendered together under a header (after any
+// ungrouped test cases). Groups may be nested, in which case their names
+// are joined with a "/".
+func (self *Fixture) Group(name string, fn func()) {
+	if self.frozen {
+		return
+	}
+	outer := self.group
+	if outer != "" {
+		name = outer + "/" + name
+	}
+	if !contains(self.groupOrder, name) {
+		self.groupOrder = append(self.groupOrder, name)
+	}
+	self.group = name
+	defer func() { self.group = outer }()
+	fn()
+}
+
 // GoTest registers a test case, to be run after any registered setup and
 // before any registered teardown. Use GoTest in favor of the Test function
 // when your action launches another goroutine, thus relenting flow of
@@ -143,10 +411,33 @@ func (self *Fixture) GoTest(description string, action func(func())) {
 	if self.frozen {
 		return
 	}
-	self.validate(description)
+	self.register(description)
 	self.tests[description] = action
 }
 
+// GoTestOrdered registers a test case like Test, but passes the action a
+// record func that goroutines launched by the action may call (safely) as
+// they complete. The action should wait for its goroutines to finish and
+// may then assert on the order in which they completed (see
+// CompletionOrder).
+func (self *Fixture) GoTestOrdered(description string, action func(record func(id int))) {
+	self.Test(description, func() { action(self.recordCompletion) })
+}
+
+func (self *Fixture) recordCompletion(id int) {
+	self.completedLock.Lock()
+	defer self.completedLock.Unlock()
+	self.completed = append(self.completed, id)
+}
+
+// CompletionOrder returns the ids recorded so far by the current test case
+// (see GoTestOrdered), in the order in which they were recorded.
+func (self *Fixture) CompletionOrder() []int {
+	self.completedLock.Lock()
+	defer self.completedLock.Unlock()
+	return append([]int{}, self.completed...)
+}
+
 // SkipGoTest registers a test case to be logged in test output but it
 // will not be executed. It is analogous to SkipTest and is meant for
 // concurrent scenarios. A call of this function is meant to aid debugging
@@ -156,9 +447,19 @@ func (self *Fixture) SkipGoTest(description string, action func(func())) {
 	if self.frozen {
 		return
 	}
-	self.validate(description)
+	self.register(description)
 	self.tests[description] = nil
-	self.skipped[description] = struct{}{}
+	self.skipped[description] = ""
+}
+
+// SkipGoTestReason is like SkipGoTest but records why the test case is
+// skipped (see SkipTestReason).
+func (self *Fixture) SkipGoTestReason(description, reason string, action func(func())) {
+	if self.frozen {
+		return
+	}
+	self.SkipGoTest(description, action)
+	self.skipped[description] = reason
 }
 
 // FocusGoTest registers a test to be run instead of any other tests not
@@ -170,12 +471,128 @@ func (self *Fixture) FocusGoTest(description string, action func(func())) {
 	if self.frozen {
 		return
 	}
-	self.validate(description)
 	self.focused[description] = struct{}{}
 	self.GoTest(description, action)
 }
 
-func (self *Fixture) validate(description string) {
+// WithSeed sets the seed used by the random source returned from Rand.
+// Pass the seed logged by a previous run to reproduce its sequence.
+func (self *Fixture) WithSeed(seed int64) {
+	if self.frozen {
+		return
+	}
+	self.seed = seed
+}
+
+// RandomizeOrder causes the test cases to run in a random order (determined
+// by the seed) rather than the order they were registered, which is useful
+// for surfacing coupling between test cases. The seed is logged so that a
+// failing order can be replayed by passing the same seed.
+func (self *Fixture) RandomizeOrder(seed int64) {
+	if self.frozen {
+		return
+	}
+	self.shuffle = true
+	self.shuffleSeed = seed
+}
+
+// FailFast causes Run to stop executing test cases once one has failed.
+// The remaining test cases are logged as not run (and are reported by
+// AssertAllRan). Teardown (and AfterAll) functions still run as usual.
+func (self *Fixture) FailFast() {
+	if self.frozen {
+		return
+	}
+	self.failFast = true
+}
+
+// Timeout limits how long each test case may take to call its done func
+// (see GoTest). A test case that overruns fails the fixture and the next
+// test case is started rather than waiting forever. Note that the
+// goroutines of a test case that timed out may still be running (and may
+// yet log output or report failures). The timeout doesn't interrupt a Test
+// case that is blocked on the calling goroutine.
+func (self *Fixture) Timeout(d time.Duration) {
+	if self.frozen {
+		return
+	}
+	self.timeout = d
+}
+
+// Parallel causes the test cases to run concurrently, at most max at a
+// time. Setup and teardown still bracket each test case, and a panicking
+// test case still fails the fixture without disturbing the others. Each
+// line of output is written atomically, but the lines of concurrent test
+// cases are interleaved. Failures are attributed to the test case whose
+// goroutine (or a goroutine started by it) reported them, and the same goes
+// for the output checked by RequireOutput and written by OutputDir. The
+// duration of each test case is logged on a line of its own (` <- "test"
+// (12ms)`) once it has finished. Features
+// that track the test case in progress by other means (Rand, Step,
+// ExpectLog, the fake clock, and WithRollback) are not supported when
+// running in parallel, and TrackMemory and AssertHeapGrowthUnder fail the
+// fixture before any test case runs. With WithFault, the test cases running
+// under one fault finish before those under the next one start.
+func (self *Fixture) Parallel(max int) {
+	if self.frozen || max < 1 {
+		return
+	}
+	self.semaphore = make(chan struct{}, max)
+	self.inFlight = make(map[uint64]*outcome)
+}
+
+// Rand returns a random source owned by the fixture. The source is re-seeded
+// before each test case so that randomized tests are reproducible. The seed
+// is logged the first time Rand is called (see WithSeed).
+func (self *Fixture) Rand() *rand.Rand {
+	if self.random == nil {
+		self.random = rand.New(rand.NewSource(self.seed))
+	}
+	if !self.seedLogged {
+		self.seedLogged = true
+		self.Logf("    (random seed: %d)\n", self.seed)
+	}
+	return self.random
+}
+
+// Step runs the action as the next numbered step of the current test case,
+// logging "Step N: description". Failures within the step reference its
+// number. Step numbering starts over with each test case.
+func (self *Fixture) Step(description string, action func()) {
+	self.steps++
+	self.step = self.steps
+	defer func() { self.step = 0 }()
+
+	self.Logf("    Step %d: %s\n", self.step, description)
+	action()
+}
+
+type fault struct {
+	name   string
+	inject func()
+}
+
+// WithFault registers a named fault point. Each test case is run once without
+// any faults and then once more per registered fault, in which case inject
+// is called (after setup) and ShouldFault(name) reports true. This allows
+// the error handling paths of the code under test to be exercised
+// systematically.
+func (self *Fixture) WithFault(name string, inject func()) {
+	if self.frozen {
+		return
+	}
+	self.faults = append(self.faults, fault{name: name, inject: inject})
+}
+
+// ShouldFault reports whether the named fault is being injected into the
+// test case in progress (see WithFault).
+func (self *Fixture) ShouldFault(name string) bool {
+	return self.activeFault != "" && self.activeFault == name
+}
+
+// register validates the description of a test case that is about to be
+// registered and records its registration order (and group, if any).
+func (self *Fixture) register(description string) {
 	if len(description) == 0 {
 		self.spoiled = true
 		self.Log("Test description must be non-blank.\n")
@@ -184,104 +601,1186 @@ func (self *Fixture) validate(description string) {
 		self.Logf(
 			"Description conflict: action already registered with this description: '%s'\n",
 			description)
+	} else {
+		self.order = append(self.order, description)
+	}
+	if self.group != "" {
+		self.groups[description] = self.group
 	}
 }
 
-// Run iterates all test cases performing the following steps:
+// Run iterates all test cases (in the order they were registered) performing
+// the following steps:
 // - If registered, run the setup function.
 // - Run the test case.
 // - If registered, run the teardown function.
 func (self *Fixture) Run() {
 	defer self.dump()
 
-	if self.frozen || len(self.tests) == 0 {
+	if self.todo != "" {
+		self.frozen = true
+		registerTODO(self.description)
+		self.Log("TODO: " + self.todo + "\n")
+		self.t.SkipNow()
+	} else if self.frozen || len(self.tests) == 0 {
 		self.t.SkipNow() // calls runtime.Goexit(), killing the current goroutine
+	} else if reason := self.platformExcluded(); reason != "" {
+		self.Log(reason)
+		self.t.SkipNow()
 	} else if self.spoiled {
-		self.t.Fail()
+		self.fail()
+	} else if reason := self.unsupportedInParallel(); reason != "" {
+		self.Log(reason)
+		self.fail()
 	} else {
 		self.runAll()
 	}
 }
 
+// unsupportedInParallel explains why the fixture can't run its test cases in
+// parallel, if that's the case (see Parallel). The heap is shared by the
+// test cases that run at the same time, so their growth can't be told apart.
+func (self *Fixture) unsupportedInParallel() string {
+	if self.semaphore != nil && (self.trackMemory || self.heapLimit > 0) {
+		return "TrackMemory and AssertHeapGrowthUnder are not supported when running in parallel (see Parallel).\n"
+	}
+	return ""
+}
+
+// TODO marks the entire fixture as pending implementation: Run executes no
+// test cases, logs the reason, and skips the fixture. The fixture is also
+// recorded so that the number of pending fixtures can be reported (see TODOs).
+func (self *Fixture) TODO(reason string) {
+	if self.frozen {
+		return
+	}
+	self.todo = reason
+}
+
+// OnlyOn restricts the fixture to the listed operating systems (compared
+// against runtime.GOOS). On any other system the entire fixture is skipped.
+func (self *Fixture) OnlyOn(goos ...string) {
+	if self.frozen {
+		return
+	}
+	self.onlyOn = append(self.onlyOn, goos...)
+}
+
+// SkipOn causes the entire fixture to be skipped on the listed operating
+// systems (compared against runtime.GOOS).
+func (self *Fixture) SkipOn(goos ...string) {
+	if self.frozen {
+		return
+	}
+	self.skipOn = append(self.skipOn, goos...)
+}
+
+// platformExcluded returns the reason the fixture should be skipped on the
+// current operating system (or "" if it shouldn't be).
+func (self *Fixture) platformExcluded() string {
+	if len(self.onlyOn) > 0 && !contains(self.onlyOn, runtime.GOOS) {
+		return fmt.Sprintf("Skipped: this fixture only runs on %v (this is %s).\n", self.onlyOn, runtime.GOOS)
+	} else if contains(self.skipOn, runtime.GOOS) {
+		return fmt.Sprintf("Skipped: this fixture doesn't run on %s.\n", runtime.GOOS)
+	}
+	return ""
+}
+
+// ExitCode returns 0 if the fixture ran without failure and 1 otherwise.
+// It is meant for standalone harnesses (call `os.Exit(f.ExitCode())`)
+// and is only accurate after Run.
+func (self *Fixture) ExitCode() int {
+	if self.failed || self.spoiled {
+		return 1
+	}
+	return 0
+}
+
+func (self *Fixture) fail() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if current := self.inProgress(); current != nil {
+		current.failed = true
+		if current.quarantined {
+			return // see Quarantine.
+		}
+	}
+	self.failed = true
+	self.t.Fail()
+}
+
+// passed reports whether the test case in progress has passed so far (or,
+// without a test case in progress, whether the fixture has).
+func (self *Fixture) passed() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if current := self.inProgress(); current != nil {
+		return !current.failed
+	}
+	return !self.failed
+}
+
+// recordFailure keeps the message of a failure (see DedupeFailures) and the
+// report logged for it (see WriteXML) for the outcome of the test case in
+// progress.
+func (self *Fixture) recordFailure(message, report string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if current := self.inProgress(); current != nil {
+		current.failures = append(current.failures, message)
+		current.reports = append(current.reports, report)
+	}
+}
+
+// inProgress returns the outcome of the test case in progress (on the
+// calling goroutine, when running in parallel), if any. The lock must be
+// held by the caller.
+func (self *Fixture) inProgress() *outcome {
+	if self.semaphore == nil {
+		return self.current
+	}
+	id, parent := goroutineIDs()
+	if current, found := self.inFlight[id]; found {
+		return current
+	}
+	if current, found := self.inFlight[parent]; found {
+		self.inFlight[id] = current // so that its own goroutines are found as well.
+		return current
+	}
+	return nil
+}
+
 func (self *Fixture) dump() {
+	self.summarizeSkipped()
+	self.summarizeFailures()
 	self.t.Log(self.output.String())
 }
 
+// summarizeSkipped lists the test cases that were skipped for a reason (in
+// the order they were registered).
+func (self *Fixture) summarizeSkipped() {
+	var lines []string
+	for _, description := range self.order {
+		if reason := self.skipped[description]; reason != "" {
+			lines = append(lines, fmt.Sprintf("  - \"%s\" (%s)\n", description, reason))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	self.Log("Skipped tests:\n")
+	for _, line := range lines {
+		self.Log(line)
+	}
+}
+
+// summarizeFailures groups identical failure messages (see DedupeFailures).
+func (self *Fixture) summarizeFailures() {
+	if !self.dedupe {
+		return
+	}
+	var messages []string
+	tests := make(map[string]int)
+	for _, outcome := range self.outcomes {
+		seen := make(map[string]bool)
+		for _, message := range outcome.failures {
+			if seen[message] {
+				continue
+			}
+			seen[message] = true
+			if tests[message] == 0 {
+				messages = append(messages, message)
+			}
+			tests[message]++
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	self.Log("Failures:\n")
+	for _, message := range messages {
+		count, noun := tests[message], "tests"
+		if count == 1 {
+			noun = "test"
+		}
+		self.Logf("  %d %s failed with: %s\n", count, noun, strings.Replace(message, "\n", "\n    ", -1))
+	}
+}
+
 func (self *Fixture) runAll() {
 	self.frozen = true
+	self.started = true
+
+	started := time.Now()
+	defer func() { self.elapsed = time.Since(started) }()
 
-	for description, test := range self.tests {
-		self.runOne(description, test)
+	if len(self.focused) > 0 {
+		registerFocused(self.description)
+	}
+	if self.shuffle {
+		self.Logf("(test order randomized with seed: %d)\n", self.shuffleSeed)
+		random := rand.New(rand.NewSource(self.shuffleSeed))
+		random.Shuffle(len(self.order), func(i, j int) {
+			self.order[i], self.order[j] = self.order[j], self.order[i]
+		})
+	}
+	if self.afterAll != nil {
+		defer self.runAfterAll()
+	}
+	if self.beforeAll != nil && !self.runBeforeAll() {
+		return
+	}
+
+	for _, description := range self.order {
+		if _, grouped := self.groups[description]; !grouped {
+			self.runOne(description, self.tests[description])
+		}
 	}
+	for _, group := range self.groupOrder {
+		self.group = group
+		self.Logf(" [%s]\n", group)
+		for _, description := range self.order {
+			if self.groups[description] == group {
+				self.runOne(description, self.tests[description])
+			}
+		}
+	}
+	self.group = ""
+	self.running.Wait()
+
+	for _, outcome := range self.outcomes { // only now that none are running in parallel.
+		if outcome.skipped {
+			self.skipped[outcome.description] = outcome.reason
+		}
+	}
+}
+
+// runBeforeAll reports whether the BeforeAll function completed without
+// panicking.
+func (self *Fixture) runBeforeAll() (completed bool) {
+	defer self.recover("BeforeAll")
+	self.beforeAll()
+	return true
+}
+
+func (self *Fixture) runAfterAll() {
+	defer self.recover("AfterAll")
+	self.afterAll()
 }
 
 func (self *Fixture) runOne(description string, test func(func())) {
-	if len(self.focused) > 0 {
-		if _, focus := self.focused[description]; focus {
-			self.execute(" -> <FOCUSED> ", description, test)
-		} else {
-			self.Logf(" -> (skipped) \"%s\"\n", description)
+	if self.failFast && !self.passed() { // no test case is in progress, so this covers the fixture.
+		self.stopped[description] = struct{}{}
+		self.Logf(" -> (not run, fail-fast) \"%s\"\n", description)
+	} else if _, focus := self.focused[description]; len(self.focused) > 0 && !focus {
+		self.logSkipped(description)
+	} else if self.filter != nil && !self.filter.MatchString(description) {
+		if _, skip := self.skipped[description]; !skip { // keep the reason given to SkipTestReason.
+			self.skipped[description] = "not matched by GOUNIT_RUN"
 		}
+		self.logSkipped(description)
+	} else if len(self.focused) > 0 {
+		self.executeWithFaults(" -> <FOCUSED> ", description, test)
 	} else if _, skip := self.skipped[description]; skip {
-		self.Logf(" -> (skipped) \"%s\"\n", description)
+		self.logSkipped(description)
+	} else if _, slow := self.slow[description]; slow && short() {
+		self.skipped[description] = "skipped in -short mode"
+		self.logSkipped(description)
+	} else if condition, conditional := self.conditions[description]; conditional && !condition() {
+		self.skipped[description] = "condition was false"
+		self.logSkipped(description)
 	} else {
-		self.execute(" -> ", description, test)
+		self.executeWithFaults(" -> ", description, test)
+	}
+}
+
+// executeWithFaults executes the test case once without any faults, then
+// once more for each registered fault (see WithFault).
+func (self *Fixture) executeWithFaults(prefix, description string, test func(func())) {
+	_, self.quarantining = self.quarantined[description]
+	defer func() { self.quarantining = false }()
+	if self.quarantining {
+		prefix += "(quarantined) "
+	}
+
+	self.execute(prefix, description, test)
+
+	for _, fault := range self.faults {
+		inject := fault.inject
+		self.switchFault(fault.name)
+		self.execute(prefix, description+" [fault: "+fault.name+"]", func(done func()) {
+			inject()
+			test(done)
+		})
+	}
+	if len(self.faults) > 0 {
+		self.switchFault("")
+	}
+}
+
+// switchFault makes name the active fault (see ShouldFault) once any test
+// cases running in parallel under the previous one have finished.
+func (self *Fixture) switchFault(name string) {
+	self.running.Wait()
+	self.activeFault = name
+}
+
+func (self *Fixture) logSkipped(description string) {
+	self.lock.Lock()
+	self.durations[description] = 0
+	self.lock.Unlock()
+	if reason := self.skipped[description]; reason != "" {
+		self.Logf(" -> (skipped: %s) \"%s\"\n", reason, description)
+	} else {
+		self.Logf(" -> (skipped) \"%s\"\n", description)
 	}
 }
 
 func (self *Fixture) execute(prefix, description string, test func(func())) {
-	defer self.recover() // recovers panic in teardown
-	defer self.teardown()
-	defer self.recover() // recovers panic in setup
+	if self.semaphore != nil {
+		self.executeConcurrently(prefix, description, test)
+		return
+	}
+
+	self.current = &outcome{description: description, group: self.group, quarantined: self.quarantining}
+	self.outcomes = append(self.outcomes, self.current)
+	defer func() { self.current = nil }()
+
+	defer func(start int) { self.writeOutputFile(description, self.output.Bytes()[start:]) }(self.output.Len())
+	started, line := time.Now(), -1
+	defer func() { self.recordDuration(self.current, description, time.Since(started), line) }()
+	defer self.runCleanups(self.current)
+	defer self.recover("teardown")
+	defer func() { self.teardown(self.passed()) }()
+	defer self.recover("teardown")
+	defer self.afterNamed(description)
+	defer self.recover("setup")
+	self.random = nil
+	self.clock = nil
+	self.steps = 0
+	self.completed = nil
+	if self.setupOnce != nil {
+		once := self.setupOnce
+		self.setupOnce = nil
+		once()
+	}
+	self.beginTransaction()
 	self.setup()
+	self.beforeNamed(description)
 	self.Logf("%s\"%s\"\n", prefix, description)
-	self.waiter.Add(1)
-	test(func() { defer self.recoverDone() }) // recovers panic in test
-	self.waiter.Wait()
+	mark := self.output.Len()
+	line = mark - 1 // the position of the newline, where the duration goes.
+	heap := self.measureHeap()
+	waiter := new(sync.WaitGroup) // fresh for each test case in case one times out (see Timeout).
+	waiter.Add(1)
+	if self.invoke(test, func() {
+		defer waiter.Done()
+		if r := recover(); r != nil { // must be called directly by the deferred done func.
+			self.panicked("test", r)
+		}
+	}) {
+		self.await(description, waiter)
+	}
+
+	self.checkLogs()
+	self.checkAssertions(self.current, description)
+	self.checkOutput(description, self.output.Len() == mark)
+	self.checkHeap(heap)
+}
+
+// invoke calls the test case, reporting whether it returned normally. A
+// panic that escapes the test case (a GoTest that panics before deferring
+// its done func, for example) is reported as such, and there's no use
+// waiting for the done func after that.
+func (self *Fixture) invoke(test func(func()), done func()) (returned bool) {
+	defer self.recover("test")
+	test(done)
+	return true
+}
+
+// recordDuration records how long the test case took (including its setup,
+// teardown, and cleanups) and appends the duration to the test case's line
+// in the output, at the given position. When running in parallel, the lines
+// of other test cases may follow, so the duration is logged on a line of its
+// own instead (and line is negative).
+func (self *Fixture) recordDuration(outcome *outcome, description string, duration time.Duration, line int) {
+	suffix := fmt.Sprintf(" (%v)", duration.Round(time.Microsecond))
+	if line < 0 {
+		self.Logf(" <- \"%s\"%s\n", description, suffix)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.durations[description] = duration
+	outcome.duration = duration
+	if line < 0 || line > self.output.Len() {
+		return
+	}
+	tail := append([]byte(nil), self.output.Bytes()[line:]...) // only the output of the test case itself.
+	self.output.Truncate(line)
+	self.output.WriteString(suffix)
+	self.output.Write(tail)
+}
+
+// Durations returns how long each test case took to run (including its
+// setup, teardown, and cleanups), keyed by description. Skipped test cases
+// have a duration of zero. Call it after Run.
+func (self *Fixture) Durations() map[string]time.Duration {
+	durations := make(map[string]time.Duration, len(self.durations))
+	for description, duration := range self.durations {
+		durations[description] = duration
+	}
+	return durations
+}
+
+// NewTestServer starts an httptest.Server for the current test case. The
+// server is closed once the test case (including its teardown) has finished,
+// even if it panics. NewTestServer must be called from within a test case
+// (or its setup); otherwise the server is closed right away.
+func (self *Fixture) NewTestServer(handler http.Handler) *httptest.Server {
+	server := httptest.NewServer(handler)
+	if !self.addCleanup(server.Close) {
+		server.Close()
+		self.Log("    NewTestServer() was called outside of a test case.\n")
+	}
+	return server
+}
+
+// Cleanup registers a function to be called once the current test case
+// (including its teardown) has finished, even if it panicked. Like
+// testing.T.Cleanup, the functions are called in the reverse of the order
+// in which they were registered. A cleanup function that panics fails the
+// fixture but doesn't prevent the rest from being called. Cleanup must be
+// called from within a test case (or its setup).
+func (self *Fixture) Cleanup(action func()) {
+	if !self.addCleanup(action) {
+		self.Log("    Cleanup() was called outside of a test case.\n")
+	}
+}
+
+// addCleanup registers a cleanup function with the test case in progress,
+// reporting whether there was one.
+func (self *Fixture) addCleanup(action func()) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	current := self.inProgress()
+	if current == nil {
+		return false
+	}
+	current.cleanups = append(current.cleanups, action)
+	return true
+}
+
+// WithRollback runs each test case (along with its setup and teardown)
+// inside a transaction started by begin. The transaction is rolled back
+// once the test case has finished (even if it failed or panicked), unless
+// the test case called Commit.
+func (self *Fixture) WithRollback(begin func() (commit func(), rollback func())) {
+	if self.frozen {
+		return
+	}
+	self.begin = begin
+}
+
+// Commit commits (rather than rolls back) the transaction of the current
+// test case (see WithRollback).
+func (self *Fixture) Commit() {
+	if self.commit == nil {
+		self.Log("    Commit() was called without a transaction (see WithRollback).\n")
+		return
+	}
+	commit := self.commit
+	self.commit = nil
+	commit()
+}
+
+func (self *Fixture) beginTransaction() {
+	if self.begin == nil {
+		return
+	}
+	commit, rollback := self.begin()
+	self.commit = commit
+	self.addCleanup(func() {
+		if self.commit != nil {
+			self.commit = nil
+			rollback()
+		}
+	})
+}
+
+func (self *Fixture) setup() {
+	for _, action := range self.setups {
+		action()
+	}
+}
+
+// runCleanups calls the cleanup functions of the test case (last first).
+func (self *Fixture) runCleanups(outcome *outcome) {
+	for {
+		self.lock.Lock()
+		last := len(outcome.cleanups) - 1
+		if last < 0 {
+			self.lock.Unlock()
+			return
+		}
+		action := outcome.cleanups[last]
+		outcome.cleanups = outcome.cleanups[:last]
+		self.lock.Unlock()
+
+		self.runCleanup(action)
+	}
+}
+
+func (self *Fixture) runCleanup(action func()) {
+	defer self.recover("cleanup")
+	action()
+}
+
+// executeConcurrently is the counterpart of execute for fixtures that run
+// their test cases in parallel (see Parallel). It runs the test case on its
+// own goroutine (once one of the limited slots is free), touching only the
+// state that is shared by all test cases.
+func (self *Fixture) executeConcurrently(prefix, description string, test func(func())) {
+	if self.setupOnce != nil {
+		self.runSetupOnce()
+	}
+	self.semaphore <- struct{}{}
+	self.running.Add(1)
+
+	outcome := &outcome{description: description, group: self.group, quarantined: self.quarantining, output: new(bytes.Buffer)}
+	self.lock.Lock()
+	self.outcomes = append(self.outcomes, outcome)
+	self.lock.Unlock()
+
+	go func() {
+		defer self.running.Done()
+		defer func() { <-self.semaphore }()
+		defer self.track(outcome)()
+		defer self.writeOutcomeFile(outcome)
+		started := time.Now()
+		defer func() { self.recordDuration(outcome, description, time.Since(started), -1) }()
+		defer self.runCleanups(outcome)
+		defer self.recover("teardown")
+		defer func() { self.teardown(self.passed()) }()
+		defer self.recover("teardown")
+		defer self.afterNamed(description)
+		defer self.recover("setup")
+		self.setup()
+		self.beforeNamed(description)
+		self.Logf("%s\"%s\"\n", prefix, description)
+		mark := self.logged(outcome)
+		waiter := new(sync.WaitGroup)
+		waiter.Add(1)
+		if self.invoke(test, func() {
+			defer waiter.Done()
+			if r := recover(); r != nil { // must be called directly by the deferred done func.
+				self.panicked("test", r)
+			}
+		}) {
+			self.await(description, waiter)
+		}
+		self.checkAssertions(outcome, description)
+		self.checkOutput(description, self.logged(outcome) == mark)
+	}()
+}
+
+// logged returns how much output the test case running in parallel has
+// logged so far.
+func (self *Fixture) logged(outcome *outcome) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return outcome.output.Len()
+}
+
+// writeOutcomeFile writes the output of a test case that ran in parallel
+// to its file (see OutputDir).
+func (self *Fixture) writeOutcomeFile(outcome *outcome) {
+	self.lock.Lock()
+	content := append([]byte(nil), outcome.output.Bytes()...)
+	self.lock.Unlock()
+	self.writeOutputFile(outcome.description, content)
+}
+
+// runSetupOnce runs the SetupOnce function ahead of the test cases that run
+// in parallel (where the recovery in execute doesn't apply).
+func (self *Fixture) runSetupOnce() {
+	once := self.setupOnce
+	self.setupOnce = nil
+	defer self.recover("setup")
+	once()
+}
+
+// track associates the calling goroutine with the outcome of the test case
+// it runs in parallel (see inProgress), returning a func that ends the
+// association (for the goroutine and any others found through it).
+func (self *Fixture) track(outcome *outcome) func() {
+	id, _ := goroutineIDs()
+	self.lock.Lock()
+	self.inFlight[id] = outcome
+	self.lock.Unlock()
+
+	return func() {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		for id, tracked := range self.inFlight {
+			if tracked == outcome {
+				delete(self.inFlight, id)
+			}
+		}
+	}
+}
+
+// goroutineIDs returns the id of the calling goroutine, along with the id
+// of the goroutine that started it (if the runtime reports it), as found in
+// the goroutine's stack trace.
+func goroutineIDs() (id, parent uint64) {
+	buffer := make([]byte, 1024)
+	for {
+		n := runtime.Stack(buffer, false)
+		if n < len(buffer) {
+			buffer = buffer[:n]
+			break
+		}
+		buffer = make([]byte, 2*len(buffer))
+	}
+	trace := string(buffer)
+	id = leadingNumber(strings.TrimPrefix(trace, "goroutine "))
+	if at := strings.LastIndex(trace, " in goroutine "); at >= 0 {
+		parent = leadingNumber(trace[at+len(" in goroutine "):])
+	}
+	return id, parent
+}
+
+// leadingNumber parses the digits at the start of text.
+func leadingNumber(text string) (number uint64) {
+	for _, digit := range text {
+		if digit < '0' || digit > '9' {
+			break
+		}
+		number = number*10 + uint64(digit-'0')
+	}
+	return number
+}
+
+// await waits for the test case to call its done func, or for the timeout
+// to elapse, whichever comes first (see Timeout).
+func (self *Fixture) await(description string, waiter *sync.WaitGroup) {
+	if self.timeout <= 0 {
+		waiter.Wait()
+		return
+	}
+	finished := make(chan struct{})
+	go func() {
+		waiter.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(self.timeout):
+		report := fmt.Sprintf("    TIMEOUT: \"%s\" exceeded %v\n", description, self.timeout)
+		self.fail()
+		self.recordFailure(fmt.Sprintf("TIMEOUT: exceeded %v", self.timeout), report)
+		self.Log(report)
+	}
+}
+
+// ExpectLog declares that the current test case should write a line
+// containing the substring to the standard logger (see package log). The
+// first call in a test case starts capturing the standard logger's output
+// (which is still written to its original destination). When the test case
+// finishes, any expected substrings missing from the captured output cause
+// the test case to fail.
+func (self *Fixture) ExpectLog(substring string) {
+	if self.capturedLogs == nil {
+		self.capturedLogs = new(bytes.Buffer)
+		self.restoreLog = log.Writer()
+		log.SetOutput(io.MultiWriter(self.restoreLog, self.capturedLogs))
+	}
+	self.expectedLogs = append(self.expectedLogs, substring)
+}
+
+func (self *Fixture) checkLogs() {
+	if self.capturedLogs == nil {
+		return
+	}
+	log.SetOutput(self.restoreLog)
+
+	var missing []string
+	for _, expected := range self.expectedLogs {
+		if !strings.Contains(self.capturedLogs.String(), expected) {
+			missing = append(missing, expected)
+		}
+	}
+	self.expectedLogs, self.capturedLogs, self.restoreLog = nil, nil, nil
+
+	if len(missing) > 0 {
+		self.fail()
+		self.Logf("    Expected log output was missing: %q\n", missing)
+	}
+}
+
+// countAssertion counts an assertion made by the test case in progress.
+func (self *Fixture) countAssertion() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if current := self.inProgress(); current != nil {
+		current.assertions++
+	}
 }
 
-func (self *Fixture) recoverDone() {
-	self.recover()
-	self.waiter.Done()
+// countWarning counts a warning (see CompactSummary).
+func (self *Fixture) countWarning() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.warnings++
+}
+
+// ExpectAssertions declares that the test case with the given description
+// should make exactly n assertions (calls to So, SkipSo, and the like), so
+// that a test case that returns early by mistake doesn't pass silently.
+func (self *Fixture) ExpectAssertions(description string, n int) {
+	if self.frozen {
+		return
+	}
+	self.expectedAssertions[description] = n
+}
+
+// WarnWithoutAssertions causes a warning to be logged (and counted, see
+// CompactSummary) for each test case that makes no assertions at all.
+func (self *Fixture) WarnWithoutAssertions(enabled bool) {
+	if self.frozen {
+		return
+	}
+	self.warnUnasserted = enabled
+}
+
+func (self *Fixture) checkAssertions(outcome *outcome, description string) {
+	if self.preview {
+		return // assertions are only logged, not made (see PreviewAssertions).
+	}
+	self.lock.Lock()
+	made := outcome.assertions
+	self.lock.Unlock()
+
+	if expected, found := self.expectedAssertions[description]; found && made != expected {
+		self.fail()
+		self.Logf("    Expected %d assertions in \"%s\" (but %d were made; see ExpectAssertions).\n",
+			expected, description, made)
+	}
+	if self.warnUnasserted && made == 0 {
+		self.countWarning()
+		self.Logf("    WARNING: \"%s\" made no assertions (see WarnWithoutAssertions).\n", description)
+	}
+}
+
+func (self *Fixture) checkOutput(description string, silent bool) {
+	if self.requireOutput && silent {
+		self.fail()
+		self.Logf("    No output was produced by \"%s\" (see RequireOutput).\n", description)
+	}
+}
+
+// measureHeap forces a garbage collection (to reduce noise) and returns the
+// number of bytes allocated on the heap, but only if memory is being tracked.
+func (self *Fixture) measureHeap() uint64 {
+	if !self.trackMemory && self.heapLimit == 0 {
+		return 0
+	}
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func (self *Fixture) checkHeap(before uint64) {
+	if !self.trackMemory && self.heapLimit == 0 {
+		return
+	}
+	growth := int64(self.measureHeap()) - int64(before)
+	if self.trackMemory {
+		self.Logf("    Heap growth: %d bytes\n", growth)
+	}
+	if self.heapLimit > 0 && growth > int64(self.heapLimit) {
+		self.fail()
+		self.Logf("    Heap grew by %d bytes (the limit is %d bytes, see AssertHeapGrowthUnder).\n", growth, self.heapLimit)
+	}
 }
 
-func (self *Fixture) recover() {
+func (self *Fixture) recover(phase string) {
 	if r := recover(); r != nil {
-		self.t.Fail()
-		self.Log(self.formatPanic(fmt.Sprint(r)))
+		self.panicked(phase, r)
 	}
 }
 
-func (self *Fixture) formatPanic(recovered string) string {
-	_, file, line, _ := runtime.Caller(4)
-	fileInfo := file + ":" + strconv.Itoa(line)
-	title := "PANIC: [" + recovered + "]"
+func (self *Fixture) panicked(phase string, recovered interface{}) {
+	if _, aborted := recovered.(abort); aborted {
+		return // the failure has already been recorded (see Fatal).
+	}
+	message := fmt.Sprint(recovered)
+	if self.panicHandler != nil {
+		fail, custom := self.panicHandler(recovered)
+		if custom != "" {
+			message = custom
+		}
+		if !fail {
+			self.Logf("    (recovered from expected panic in %s: [%s])\n", phase, message)
+			return
+		}
+	}
+	report := self.formatPanic(phase, message, callers())
+	self.fail()
+	self.recordFailure("PANIC in "+phase+": ["+message+"]", report)
+	self.Log(report)
+}
+
+// formatPanic reports the panic along with where it happened, which is
+// found in the stack (captured while recovering) as the innermost frame
+// outside of this package.
+func (self *Fixture) formatPanic(phase, recovered string, stack []runtime.Frame) string {
+	for len(stack) > 0 && internal(stack[0]) {
+		stack = stack[1:]
+	}
+	fileInfo := "(unknown location)"
+	if len(stack) > 0 {
+		fileInfo = stack[0].File + ":" + strconv.Itoa(stack[0].Line)
+	}
+	title := "PANIC in " + phase + ": [" + recovered + "]"
 	divider := strings.Repeat("*", max(len(fileInfo), len(title)))
+	if self.stackTraces {
+		for _, frame := range stack {
+			fileInfo += "\n  " + frame.Function + "(...)\n      " + frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+	}
 	return "\n\n  " + divider + "\n\n  " +
 		title + "\n\n  " +
 		fileInfo + "\n\n  " +
 		divider + "\n"
 }
 
+// ShowStackTraces causes the full stack trace of each panic (from where it
+// happened) to be logged, rather than just where it happened.
+func (self *Fixture) ShowStackTraces() {
+	if self.frozen {
+		return
+	}
+	self.stackTraces = true
+}
+
 // This method stands in as a 'So' call with a required description--
 // (a-la-`github.com/smartystreets/goconvey/convey/assertions.So`)
-func (self *Fixture) So(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) {
+// It reports whether the assertion passed so that callers can avoid
+// follow-on failures (like dereferencing a nil pointer).
+func (self *Fixture) So(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) bool {
+	return self.so(description, actual, so, expected...)
+}
+
+// so performs the work of So (and the SoXxx methods), reporting whether the
+// assertion passed.
+func (self *Fixture) so(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) bool {
+	if self.preview {
+		self.logPreview(description, so, expected)
+		return true
+	}
+	self.countAssertion()
 	ok, result := assertions.So(actual, so, expected...)
-	self.Log("    + ", description+"\n")
+	self.Log("    ", self.marker(ok), " ", description+"\n")
 	if !ok {
-		self.t.Fail()
-		self.Log(self.formatResult(description, result))
+		report := self.formatResult("FAILED", description, result)
+		self.fail()
+		self.recordFailure(result, report)
+		self.Log(report)
+	}
+	if self.afterAssertion != nil {
+		self.afterAssertion(description, ok)
+	}
+	return ok
+}
+
+// Warn evaluates an assertion like So, but a failure is only logged (as a
+// WARNING) and counted (see CompactSummary); it doesn't fail the fixture.
+// Use it for soft checks, like performance hints.
+func (self *Fixture) Warn(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) {
+	self.warn(description, actual, so, expected...)
+}
+
+// warn performs the work of Warn.
+func (self *Fixture) warn(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) {
+	if self.preview {
+		self.logPreview(description, so, expected)
+		return
+	}
+	ok, result := assertions.So(actual, so, expected...)
+	if ok {
+		self.Log("    ", self.marker(ok), " ", description+"\n")
+		return
+	}
+	self.countWarning()
+	self.Log("    ! ", description+"\n")
+	self.Log(self.formatResult("WARNING", description, result))
+}
+
+// logPreview logs the assertion that would have been made (see
+// PreviewAssertions).
+func (self *Fixture) logPreview(description string, so func(actual interface{}, expected ...interface{}) string, expected []interface{}) {
+	name := runtime.FuncForPC(reflect.ValueOf(so).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = name[strings.Index(name, ".")+1:]
+
+	comparison := []string{name}
+	for _, value := range expected {
+		comparison = append(comparison, fmt.Sprintf("%#v", value))
+	}
+	self.Logf("    ? %s (%s)\n", description, strings.Join(comparison, " "))
+}
+
+// Error records a failure (logging the args) and continues execution of
+// the test case, like testing.T.Error.
+func (self *Fixture) Error(args ...interface{}) {
+	self.reportError("Error", fmt.Sprint(args...))
+}
+
+// Fatal records a failure (logging the args) and stops execution of the
+// test case, like testing.T.Fatal. Any registered teardown still runs, as
+// do the remaining test cases. Fatal must be called from the goroutine
+// running the test case, not from other goroutines it starts, and a
+// recover() in the test case will swallow the abort (see abort).
+func (self *Fixture) Fatal(args ...interface{}) {
+	self.reportError("Fatal", fmt.Sprint(args...))
+	panic(abort{})
+}
+
+// reportError records and logs a failure (see Error and Fatal).
+func (self *Fixture) reportError(kind, message string) {
+	report := self.formatResult("FAILED", kind, message)
+	self.fail()
+	self.recordFailure(kind+": "+message, report)
+	self.Log(report)
+}
+
+// SkipNow stops execution of the test case, recording it as skipped (for
+// the reason given) rather than failed, like testing.T.Skip. Any registered
+// teardown still runs, as do the remaining test cases. SkipNow must be
+// called from the goroutine running the test case, not from other
+// goroutines it starts, and a recover() in the test case will swallow the
+// abort (see abort).
+func (self *Fixture) SkipNow(reason string) {
+	self.Logf("    (skipped: %s)\n", reason)
+	self.lock.Lock()
+	if current := self.inProgress(); current != nil {
+		current.skipped = true
+		current.reason = reason
+	}
+	self.lock.Unlock()
+	panic(abort{})
+}
+
+// abort is panicked (and then recovered) to stop a test case (see Fatal).
+// Unlike testing.T.FailNow, it can't use runtime.Goexit: Test actions run on
+// the goroutine that called Run, which would stop as well. The catch is that
+// a recover() in the test case will swallow the abort and carry on.
+type abort struct{}
+
+func (self *Fixture) marker(ok bool) string {
+	if !self.inline {
+		return "+"
+	} else if ok {
+		return "✓"
+	}
+	return "✗"
+}
+
+// AfterEachAssertion registers a function to be called after each assertion
+// (see So) with the assertion's description and outcome. It is useful for
+// collecting metrics or custom logging. Subsequent calls to this function
+// overwrite the previously registered function.
+func (self *Fixture) AfterEachAssertion(action func(description string, passed bool)) {
+	if self.frozen {
+		return
+	}
+	self.afterAssertion = action
+}
+
+// OnPanic registers a function that classifies values recovered from panics
+// (in setup, tests, or teardown). Returning fail=false marks the panic as
+// expected so that it doesn't fail the test case. A non-empty message takes
+// the place of the recovered value in the output.
+func (self *Fixture) OnPanic(handler func(recovered interface{}) (fail bool, message string)) {
+	if self.frozen {
+		return
+	}
+	self.panicHandler = handler
+}
+
+// RequireOutput causes any test case that produces no output of its own
+// (assertions, log messages, etc.) to fail. It catches test cases that
+// silently do nothing.
+func (self *Fixture) RequireOutput(enabled bool) {
+	if self.frozen {
+		return
+	}
+	self.requireOutput = enabled
+}
+
+// TrackMemory causes the heap growth of each test case (measured after a
+// forced garbage collection before and after the test) to be logged.
+func (self *Fixture) TrackMemory(enabled bool) {
+	if self.frozen {
+		return
 	}
+	self.trackMemory = enabled
 }
 
-func (self *Fixture) SkipSo(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) {
+// AssertHeapGrowthUnder causes any test case whose heap growth (measured as
+// with TrackMemory) exceeds the limit to fail. It is meant to catch memory
+// retained by repeated operations.
+func (self *Fixture) AssertHeapGrowthUnder(bytes uint64) {
+	if self.frozen {
+		return
+	}
+	self.heapLimit = bytes
+}
+
+// DedupeFailures adds a summary (after all test cases have run) that
+// groups identical failure messages, reporting how many test cases failed
+// with each one.
+func (self *Fixture) DedupeFailures(enabled bool) {
+	if self.frozen {
+		return
+	}
+	self.dedupe = enabled
+}
+
+// PreviewAssertions causes So (along with the SoXxx methods and Warn) to
+// log each assertion's description and intended comparison without
+// executing it (and so without failing). Use it to generate a checklist of
+// what the test cases verify.
+func (self *Fixture) PreviewAssertions(enabled bool) {
+	if self.frozen {
+		return
+	}
+	self.preview = enabled
+}
+
+// InlineResults causes each assertion's line in the output to be marked with
+// its outcome ("✓" or "✗") rather than the neutral "+".
+func (self *Fixture) InlineResults(enabled bool) {
+	if self.frozen {
+		return
+	}
+	self.inline = enabled
+}
+
+// SkipSo logs the assertion as skipped (without checking it) and returns
+// true, as if it had passed.
+func (self *Fixture) SkipSo(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) bool {
+	self.countAssertion()
 	self.Log("    + (skipped) ", description+"\n")
+	return true
+}
+
+// SkipSoReason is like SkipSo but records why the assertion is parked.
+func (self *Fixture) SkipSoReason(description, reason string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) bool {
+	self.countAssertion()
+	self.Log("    + (skipped: ", reason, ") ", description+"\n")
+	return true
+}
+
+// Pass records an explicit passing checkpoint without comparing anything. It
+// documents tests that succeed by reaching a certain point without panicking
+// (and is reported to AfterEachAssertion like any other assertion).
+func (self *Fixture) Pass(description string) {
+	self.so(description, nil, shouldPass)
+}
+
+// Assert is like So except that a failure also stops execution of the test
+// case (like Fatal), sparing the rest of the test case from failing in
+// confusing ways as a consequence. Any registered teardown still runs, as do
+// the remaining test cases. Assert must be called from the goroutine running
+// the test case, not from other goroutines it starts, and a recover() in the
+// test case will swallow the abort (see abort).
+func (self *Fixture) Assert(description string, actual interface{}, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) {
+	if !self.so(description, actual, so, expected...) {
+		panic(abort{})
+	}
+}
+
+func shouldPass(actual interface{}, expected ...interface{}) string {
+	return success
 }
 
-func (self *Fixture) formatResult(description, result string) string {
-	_, file, line, _ := runtime.Caller(2)
-	fileInfo := file + ":" + strconv.Itoa(line)
-	title := "FAILED: \"" + description + "\""
+// Helper marks the calling function as a test helper, like
+// testing.T.Helper: failures reported from within it (or from other helpers
+// it calls) are attributed to the line that called it.
+func (self *Fixture) Helper() {
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.helpers == nil {
+		self.helpers = make(map[string]struct{})
+	}
+	self.helpers[runtime.FuncForPC(pc).Name()] = struct{}{}
+}
+
+// location returns the file and line of the innermost caller that is
+// neither part of this package (excluding its tests) nor a helper (see
+// Helper).
+func (self *Fixture) location() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, frame := range callers() {
+		if _, helper := self.helpers[frame.Function]; !helper && !internal(frame) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+	}
+	return "(unknown location)"
+}
+
+// callers returns the frames of the stack of the calling goroutine.
+func callers() (stack []runtime.Frame) {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frame)
+		if !more {
+			return stack
+		}
+	}
+}
+
+// internal reports whether the frame belongs to the runtime or to this
+// package (but not to its tests).
+func internal(frame runtime.Frame) bool {
+	if strings.HasPrefix(frame.Function, "runtime.") {
+		return true
+	}
+	return filepath.Dir(frame.File) == packageDirectory && !strings.HasSuffix(frame.File, "_test.go")
+}
+
+// packageDirectory is where the source files of this package were compiled.
+var packageDirectory = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+func (self *Fixture) formatResult(kind, description, result string) string {
+	fileInfo := self.location()
+	title := kind + ": \"" + description + "\""
+	if self.step > 0 {
+		title = kind + " (Step " + strconv.Itoa(self.step) + "): \"" + description + "\""
+	}
 	divider := strings.Repeat("*", max(len(fileInfo), len(title)))
 	message := "\n    " + divider + "\n\n    " + title + "\n\n"
 	for _, line := range strings.Split(result, "\n") {
@@ -291,11 +1790,22 @@ func (self *Fixture) formatResult(description, result string) string {
 }
 
 func (self *Fixture) Log(args ...interface{}) {
-	self.output.WriteString(fmt.Sprint(args...))
+	self.write(fmt.Sprint(args...))
 }
 
 func (self *Fixture) Logf(message string, args ...interface{}) {
-	self.output.WriteString(fmt.Sprintf(message, args...))
+	self.write(fmt.Sprintf(message, args...))
+}
+
+// write appends text to the output (and to that of the test case in
+// progress, when running in parallel).
+func (self *Fixture) write(text string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.output.WriteString(text)
+	if current := self.inProgress(); current != nil && current.output != nil {
+		current.output.WriteString(text)
+	}
 }
 
 // A represents an abbreviation of the function signatures implemented by the
@@ -307,6 +1817,19 @@ type A func(
 	expected ...interface{},
 )
 
+// short reports whether tests are running in -short mode. It is a variable
+// so that -short mode can be simulated when testing this package.
+var short = testing.Short
+
+func contains(items []string, item string) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+	return false
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -377,4 +1900,31 @@ var (
 	ShouldHappenWithin         = assertions.ShouldHappenWithin
 	ShouldNotHappenWithin      = assertions.ShouldNotHappenWithin
 	ShouldBeChronological      = assertions.ShouldBeChronological
+
+	ShouldBeNumerically = shouldBeNumerically
+	ShouldHaveKeys      = shouldHaveKeys
+
+	ShouldEqualIgnoringCase    = shouldEqualIgnoringCase
+	ShouldNotEqualIgnoringCase = shouldNotEqualIgnoringCase
+
+	ShouldHavePrefix = shouldHavePrefix
+	ShouldBePrefixOf = shouldBePrefixOf
+
+	ShouldBeStrictlyBetween = shouldBeStrictlyBetween
+	ShouldBeInRange         = shouldBeInRange
+
+	ShouldBeSameInstant = shouldBeSameInstant
+
+	ShouldBeNaN    = shouldBeNaN
+	ShouldBeInf    = shouldBeInf
+	ShouldBeFinite = shouldBeFinite
+
+	ShouldBeMinHeap = shouldBeMinHeap
+	ShouldBeMaxHeap = shouldBeMaxHeap
+
+	ShouldBeError = shouldBeError
+
+	ShouldBePowerOfTwo  = shouldBePowerOfTwo
+	ShouldBeDivisibleBy = shouldBeDivisibleBy
+	ShouldBeMultipleOf  = shouldBeMultipleOf
 )
@@ -1,6 +1,21 @@
 package gounit
 
-import "testing"
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 /*
 It should be noted that these tests verify that registered functions
@@ -131,6 +146,40 @@ func TestPassingTests(t *testing.T) {
 	}
 }
 
+func TestGoTestOrdered(t *testing.T) {
+	spy := new(spyT)
+	var order []int
+
+	f := NewFixture("A", spy)
+	f.GoTestOrdered("B1", func(record func(id int)) {
+		waiter := new(sync.WaitGroup)
+		waiter.Add(2)
+		started := make(chan struct{})
+		go func() {
+			defer waiter.Done()
+			<-started
+			record(1)
+		}()
+		go func() {
+			defer waiter.Done()
+			record(2)
+			close(started)
+		}()
+		waiter.Wait()
+
+		order = f.CompletionOrder()
+		f.So("Worker 2 should finish before worker 1", order, ShouldResemble, []int{2, 1})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(order, ShouldResemble, []int{2, 1}); !ok {
+		t.Error("\n" + message)
+	}
+}
+
 func TestFailingTest(t *testing.T) {
 	spy := new(spyT)
 
@@ -238,6 +287,241 @@ func TestSkippedTests(t *testing.T) {
 	}
 }
 
+func TestSkippedTestsSummary(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.SkipTestReason("skip2", "flaky", func() {})
+	f.SkipTest("skip3", func() {})
+	f.Test("hi", func() {})
+	f.SkipTestReason("skip1", "not implemented", func() {})
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldEndWith, "Skipped tests:\n  - \"skip2\" (flaky)\n  - \"skip1\" (not implemented)\n"); !ok {
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", new(spyT))
+	f.SkipTest("skip", func() {})
+	f.Test("hi", func() {})
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "Skipped tests:"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSlowTestsSkippedInShortMode(t *testing.T) {
+	short = func() bool { return true }
+	defer func() { short = testing.Short }()
+
+	slow, fast := false, false
+
+	f := NewFixture("A", new(spyT))
+	f.SlowTest("slow", func() { slow = true })
+	f.Test("fast", func() { fast = true })
+	f.Run()
+
+	if ok, message := So(slow, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(fast, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> (skipped: skipped in -short mode) \"slow\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSlowTestsRunNormally(t *testing.T) {
+	short = func() bool { return false }
+	defer func() { short = testing.Short }()
+
+	slow := false
+
+	f := NewFixture("A", new(spyT))
+	f.SlowTest("slow", func() { slow = true })
+	f.Run()
+
+	if ok, message := So(slow, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestTestWhen(t *testing.T) {
+	enabled := false
+	ran, skipped := false, false
+
+	f := NewFixture("A", new(spyT))
+	f.TestWhen(func() bool { return enabled }, "enabled", func() { ran = true })
+	f.TestWhen(func() bool { return false }, "disabled", func() { skipped = true })
+	enabled = true // conditions are evaluated at Run.
+	f.Run()
+
+	if ok, message := So(ran, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(skipped, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> (skipped: condition was false) \"disabled\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.Test("ungrouped", func() {})
+	f.Group("Reading", func() {
+		f.Test("read 1", func() {})
+		f.Test("read 2", func() {})
+	})
+	f.Group("Writing", func() {
+		f.Test("write 1", func() {})
+	})
+	f.Run()
+
+	groups := map[string]string{}
+	for _, outcome := range f.outcomes {
+		groups[outcome.description] = outcome.group
+	}
+	expected := map[string]string{"ungrouped": "", "read 1": "Reading", "read 2": "Reading", "write 1": "Writing"}
+	if ok, message := So(groups, ShouldResemble, expected); !ok {
+		t.Error("\n" + message)
+	}
+
+	output := f.output.String()
+	reading, writing := strings.Index(output, " [Reading]\n"), strings.Index(output, " [Writing]\n")
+	if reading < 0 || writing < 0 {
+		t.Fatal("Expected group headers in the output:\n" + output)
+	}
+	for _, description := range []string{"\"read 1\"", "\"read 2\""} {
+		if at := strings.Index(output, description); at < reading || at > writing {
+			t.Errorf("Expected %s under the 'Reading' header:\n%s", description, output)
+		}
+	}
+	if at := strings.Index(output, "\"write 1\""); at < writing {
+		t.Error("Expected \"write 1\" under the 'Writing' header:\n" + output)
+	}
+	if at := strings.Index(output, "\"ungrouped\""); at > reading {
+		t.Error("Expected ungrouped tests before any group:\n" + output)
+	}
+}
+
+func TestSetupOnceWithPerTestTeardown(t *testing.T) {
+	setups, teardowns := 0, 0
+	var observed []int
+
+	f := NewFixture("A", new(spyT))
+	f.SetupOnce(func() { setups++ })
+	f.Teardown(func() { teardowns++ })
+	f.Test("B1", func() { observed = append(observed, setups) })
+	f.Test("B2", func() { observed = append(observed, setups) })
+	f.Test("B3", func() { observed = append(observed, setups) })
+	f.Run()
+
+	if ok, message := So(setups, ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(teardowns, ShouldEqual, 3); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(observed, ShouldResemble, []int{1, 1, 1}); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestTestsRunInRegistrationOrder(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.Test("E", func() {})
+	f.Test("B", func() {})
+	f.SkipTest("D", func() {})
+	f.GoTest("A", func(done func()) { done() })
+	f.Test("C", func() {})
+	f.Run()
+
+	expected := "A\n" +
+		" -> \"E\"\n" +
+		" -> \"B\"\n" +
+		" -> (skipped) \"D\"\n" +
+		" -> \"A\"\n" +
+		" -> \"C\"\n"
+	if ok, message := So(withoutDurations(f.output.String()), ShouldStartWith, expected); !ok {
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", new(spyT))
+	f.SlowTest("B", func() {})
+	f.FocusTest("A", func() {})
+	f.FocusGoTest("C", func(done func()) { done() })
+	f.TestWhen(func() bool { return true }, "D", func() {})
+
+	if ok, message := So(f.order, ShouldResemble, []string{"B", "A", "C", "D"}); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestQuarantinedTestsDoNotFailTheFixture(t *testing.T) {
+	ran := false
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {})
+	f.Quarantine("Flaky", func() {
+		ran = true
+		f.So("Sometimes fails", 1, ShouldEqual, 2)
+	})
+	f.Run()
+
+	if ok, message := So(ran, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.ExitCode(), ShouldEqual, 0); !ok {
+		t.Error("\n" + message)
+	}
+	flaky := f.outcomes[1]
+	flaky.reports, flaky.duration = nil, 0 // not of interest here.
+	if ok, message := So(flaky, ShouldResemble, &outcome{description: "Flaky", quarantined: true, assertions: 1, failed: true, failures: []string{"Expected: '2'\nActual:   '1'\n(Should be equal)"}}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> (quarantined) \"Flaky\""); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "FAILED: \"Sometimes fails\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestRandomizeOrder(t *testing.T) {
+	run := func(seed int64) (order []string, output string) {
+		f := NewFixture("A", new(spyT))
+		f.RandomizeOrder(seed)
+		for _, description := range []string{"B1", "B2", "B3", "B4", "B5", "B6"} {
+			description := description
+			f.Test(description, func() { order = append(order, description) })
+		}
+		f.Run()
+		return order, f.output.String()
+	}
+
+	first, output := run(42)
+	second, _ := run(42)
+
+	if ok, message := So(first, ShouldResemble, second); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(first, ShouldNotResemble, []string{"B1", "B2", "B3", "B4", "B5", "B6"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(len(first), ShouldEqual, 6); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(output, ShouldContainSubstring, "(test order randomized with seed: 42)"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
 func TestFocusedTests(t *testing.T) {
 	spy := new(spyT)
 
@@ -285,6 +569,23 @@ func TestSkippedSoAssertion(t *testing.T) {
 	}
 }
 
+func TestSkippedSoAssertionWithReason(t *testing.T) {
+	spy := new(spyT)
+
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SkipSoReason("would fail", "waiting on a fix", false, ShouldBeTrue)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error(message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "    + (skipped: waiting on a fix) would fail\n"); !ok {
+		t.Error(message)
+	}
+}
+
 func TestSetup(t *testing.T) {
 	spy := new(spyT)
 
@@ -332,6 +633,23 @@ func TestTeardown(t *testing.T) {
 	}
 }
 
+func TestNamedHooks(t *testing.T) {
+	var events []string
+
+	f := NewFixture("A", new(spyT))
+	f.Setup(func() { events = append(events, "setup") })
+	f.Teardown(func() { events = append(events, "teardown") })
+	f.BeforeEachNamed(func(description string) { events = append(events, "before "+description) })
+	f.AfterEachNamed(func(description string) { events = append(events, "after "+description) })
+	f.Test("B1", func() { events = append(events, "B1") })
+	f.Run()
+
+	expected := []string{"setup", "before B1", "B1", "after B1", "teardown"}
+	if ok, message := So(events, ShouldResemble, expected); !ok {
+		t.Error("\n" + message)
+	}
+}
+
 func TestTeardownPanics(t *testing.T) {
 	spy := new(spyT)
 	f := NewFixture("A", spy)
@@ -344,22 +662,1558 @@ func TestTeardownPanics(t *testing.T) {
 	}
 }
 
-func TestFixtureDisabledAfterRun(t *testing.T) {
+func TestPanicPhaseAttribution(t *testing.T) {
+	for _, phase := range []string{"setup", "test", "teardown"} {
+		spy := new(spyT)
+		boom := func(at string) func() {
+			return func() {
+				if at == phase {
+					panic("GOPHERS!")
+				}
+			}
+		}
+
+		f := NewFixture("A", spy)
+		f.Setup(boom("setup"))
+		f.Teardown(boom("teardown"))
+		f.Test("B1", boom("test"))
+		f.Run()
+
+		if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+			t.Error("\n" + message)
+		}
+		if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in "+phase+": [GOPHERS!]"); !ok {
+			t.Error("\n" + message)
+		}
+		if ok, message := So(strings.Count(f.output.String(), "PANIC"), ShouldEqual, 1); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+func TestSteps(t *testing.T) {
 	spy := new(spyT)
 
 	f := NewFixture("A", spy)
-	f.Test("This runs", func() {})
+	f.Test("B1", func() {
+		f.Step("first", func() { f.So("passes", 1, ShouldEqual, 1) })
+		f.Step("second", func() { f.So("fails", 1, ShouldEqual, 2) })
+		f.Step("third", func() { f.So("passes", 1, ShouldEqual, 1) })
+	})
+	f.Test("B2", func() {
+		f.Step("first again", func() {})
+	})
+	f.Run()
+
+	output := f.output.String()
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	for _, expected := range []string{"Step 1: first\n", "Step 2: second\n", "Step 3: third\n", "Step 1: first again\n"} {
+		if ok, message := So(output, ShouldContainSubstring, expected); !ok {
+			t.Error("\n" + message)
+		}
+	}
+	if ok, message := So(output, ShouldContainSubstring, "FAILED (Step 2): \"fails\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAfterEachAssertion(t *testing.T) {
+	type record struct {
+		description string
+		passed      bool
+	}
+	var records []record
+
+	f := NewFixture("A", new(spyT))
+	f.AfterEachAssertion(func(description string, passed bool) {
+		records = append(records, record{description, passed})
+	})
+	f.Test("B1", func() {
+		f.So("passes", 1, ShouldEqual, 1)
+		f.So("fails", 1, ShouldEqual, 2)
+		f.SoAllKeys("also passes", map[int]int{}, func(interface{}) bool { return false })
+	})
+	f.Run()
+
+	expected := []record{{"passes", true}, {"fails", false}, {"also passes", true}}
+	if ok, message := So(records, ShouldResemble, expected); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestPass(t *testing.T) {
+	var records []string
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.AfterEachAssertion(func(description string, passed bool) {
+		if passed {
+			records = append(records, description)
+		}
+	})
+	f.ExpectAssertions("B1", 1)
+	f.WarnWithoutAssertions(true)
+	f.Test("B1", func() {
+		f.Pass("Reached the end without panicking")
+	})
 	f.Run()
 
 	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
-		t.Error(message)
+		t.Error("\n" + message)
+	}
+	if ok, message := So(records, ShouldResemble, []string{"Reached the end without panicking"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "+ Reached the end without panicking"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "WARNING"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.warnings, ShouldEqual, 0); !ok {
+		t.Error("\n" + message)
 	}
+}
 
-	f.Test("This doesn't run", func() { f.So("really, this doesn't run", true, ShouldBeFalse) })
+func TestRequireOutput(t *testing.T) {
+	spy := new(spyT)
+
+	f := NewFixture("A", spy)
+	f.RequireOutput(true)
+	f.Test("B1", func() { f.Log("    Something to say\n") })
 	f.Run()
 
 	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
-		t.Error(message)
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", spy)
+	f.RequireOutput(true)
+	f.Test("B1", func() {})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+var retained [][]byte
+
+func TestHeapGrowth(t *testing.T) {
+	spy := new(spyT)
+
+	f := NewFixture("A", spy)
+	f.TrackMemory(true)
+	f.AssertHeapGrowthUnder(1 << 20)
+	f.Test("B1", func() { _ = make([]byte, 10<<20) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Heap growth: "); !ok {
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", spy)
+	f.AssertHeapGrowthUnder(1 << 20)
+	f.Test("B1", func() { retained = append(retained, make([]byte, 10<<20)) })
+	f.Run()
+	retained = nil
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestOnlyOn(t *testing.T) {
+	spy := new(spyT)
+	ran := false
+
+	f := NewFixture("A", spy)
+	f.OnlyOn("not-" + runtime.GOOS)
+	f.Test("B1", func() { ran = true })
+	f.Run()
+
+	if ok, message := So(spy.skipped, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ran, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.OnlyOn("not-"+runtime.GOOS, runtime.GOOS)
+	f.Test("B1", func() { ran = true })
+	f.Run()
+
+	if ok, message := So(spy.skipped, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ran, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSkipOn(t *testing.T) {
+	spy := new(spyT)
+	ran := false
+
+	f := NewFixture("A", spy)
+	f.SkipOn(runtime.GOOS)
+	f.Test("B1", func() { ran = true })
+	f.Run()
+
+	if ok, message := So(spy.skipped, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ran, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.SkipOn("not-" + runtime.GOOS)
+	f.Test("B1", func() { ran = true })
+	f.Run()
+
+	if ok, message := So(spy.skipped, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(ran, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestError(t *testing.T) {
+	spy := new(spyT)
+	finished := false
+
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.Error("GOPHERS!")
+		finished = true
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(finished, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "GOPHERS!"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestFatal(t *testing.T) {
+	spy := new(spyT)
+	finished, teardown, next := false, 0, false
+
+	f := NewFixture("A", spy)
+	f.Teardown(func() { teardown++ })
+	f.Test("B1", func() {
+		f.Fatal("GOPHERS!")
+		finished = true
+	})
+	f.Test("B2", func() { next = true })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(finished, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(teardown, ShouldEqual, 2); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(next, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "PANIC"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestExpectLog(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	spy := new(spyT)
+
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.ExpectLog("connecting")
+		f.ExpectLog("connected")
+		log.Println("connecting to the database")
+		log.Println("connected!")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.ExpectLog("connecting")
+		f.ExpectLog("connected")
+		log.Println("connecting to the database")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, `missing: ["connected"]`); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(log.Writer() == ioutil.Discard, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestNewTestServerIsClosedAfterTestCase(t *testing.T) {
+	var url string
+	torndown := false
+	spy := new(spyT)
+	f := NewFixture("Test server", spy)
+	f.Teardown(func() { torndown = true })
+	f.Test("Serve a request", func() {
+		server := f.NewTestServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(http.StatusTeapot)
+		}))
+		url = server.URL
+
+		response, err := http.Get(url)
+		f.So("The request succeeds", err, ShouldBeNil)
+		defer response.Body.Close()
+		f.So("The handler responds", response.StatusCode, ShouldEqual, http.StatusTeapot)
+		panic("the server should still be closed")
+	})
+	f.Run()
+
+	if !torndown {
+		t.Error("Expected teardown to run.")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("Expected the server to be closed after the test case.")
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "+ The handler responds"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestNewTestServerOutsideTestCase(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("Test server", spy)
+	server := f.NewTestServer(http.NotFoundHandler())
+
+	if _, err := http.Get(server.URL); err == nil {
+		t.Error("Expected the server to be closed right away.")
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "NewTestServer() was called outside of a test case."); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestOnPanicClassifiesRecoveredValues(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("Panic handler", spy)
+	f.OnPanic(func(recovered interface{}) (bool, string) {
+		if recovered == "bad input" {
+			return false, "rejected bad input"
+		}
+		return true, ""
+	})
+	f.Test("Bad input panics", func() { panic("bad input") })
+	f.Run()
+
+	if spy.failed {
+		t.Error("Expected the classified panic not to fail the fixture:\n" + f.output.String())
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "(recovered from expected panic in test: [rejected bad input])"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("Panic handler", spy)
+	f.OnPanic(func(recovered interface{}) (bool, string) { return true, "classified: " + fmt.Sprint(recovered) })
+	f.Test("Unexpected panic", func() { panic("boom") })
+	f.Run()
+
+	if !spy.failed {
+		t.Error("Expected an unclassified panic to fail the fixture.")
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in test: [classified: boom]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestWithFaultRunsEachTestOncePerFault(t *testing.T) {
+	var runs []string
+	var injected []string
+
+	spy := new(spyT)
+	f := NewFixture("Faults", spy)
+	f.WithFault("disk full", func() { injected = append(injected, "disk full") })
+	f.WithFault("timeout", func() { injected = append(injected, "timeout") })
+	f.Test("Save", func() {
+		switch {
+		case f.ShouldFault("disk full"):
+			runs = append(runs, "disk full")
+		case f.ShouldFault("timeout"):
+			runs = append(runs, "timeout")
+		default:
+			runs = append(runs, "baseline")
+		}
+	})
+	f.Run()
+
+	if ok, message := So(runs, ShouldResemble, []string{"baseline", "disk full", "timeout"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(injected, ShouldResemble, []string{"disk full", "timeout"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> \"Save [fault: timeout]\""); !ok {
+		t.Error("\n" + message)
+	}
+	if f.ShouldFault("timeout") {
+		t.Error("Expected no fault to be active after the fixture ran.")
+	}
+}
+
+func TestDedupeFailures(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("Dedupe", spy)
+	f.DedupeFailures(true)
+	f.Test("B1", func() { f.So("Broken", 1, ShouldEqual, 2) })
+	f.Test("B2", func() { f.So("Broken", 1, ShouldEqual, 2) })
+	f.Test("B3", func() { f.So("Broken", 1, ShouldEqual, 2) })
+	f.Test("B4", func() { panic("boom") })
+	f.Test("B5", func() {})
+	f.Run()
+
+	output := f.output.String()
+	if ok, message := So(strings.Count(output, "3 tests failed with: Expected: '2'"), ShouldEqual, 1); !ok {
+		t.Error("\n" + message + "\n" + output)
+	}
+	if ok, message := So(output, ShouldContainSubstring, "1 test failed with: PANIC in test: [boom]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestWithRollback(t *testing.T) {
+	var log []string
+
+	spy := new(spyT)
+	f := NewFixture("Transactions", spy)
+	f.WithRollback(func() (func(), func()) {
+		log = append(log, "begin")
+		return func() { log = append(log, "commit") }, func() { log = append(log, "rollback") }
+	})
+	f.Teardown(func() { log = append(log, "teardown") })
+	f.Test("Fails", func() { f.So("Fails", 1, ShouldEqual, 2) })
+	f.Run()
+
+	if ok, message := So(log, ShouldResemble, []string{"begin", "teardown", "rollback"}); !ok {
+		t.Error("\n" + message)
+	}
+
+	log = nil
+	f = NewFixture("Transactions", spy)
+	f.WithRollback(func() (func(), func()) {
+		log = append(log, "begin")
+		return func() { log = append(log, "commit") }, func() { log = append(log, "rollback") }
+	})
+	f.Test("Panics", func() { panic("boom") })
+	f.Test("Commits", func() { f.Commit() })
+	f.Run()
+
+	if ok, message := So(strings.Count(strings.Join(log, " "), "begin"), ShouldEqual, 2); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(strings.Count(strings.Join(log, " "), "rollback"), ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(strings.Count(strings.Join(log, " "), "commit"), ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestWarn(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.Warn("Fast enough", 150, ShouldBeLessThan, 100)
+		f.Warn("Small enough", 1, ShouldBeLessThan, 100)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "    ! Fast enough\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "WARNING: \"Fast enough\""); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "gounit_test.go:"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.CompactSummary(), ShouldStartWith, "[PASS] A (1/1, 1 warning, "); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestPreviewAssertions(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.PreviewAssertions(true)
+	f.Test("B1", func() {
+		f.So("Would fail", 1, ShouldEqual, 2)
+		f.So("Would panic", nil, func(interface{}, ...interface{}) string { panic("executed") })
+		f.SoBackoffGrows("Would also fail", []time.Duration{2, 1})
+		f.Warn("Would warn", "a", ShouldStartWith, "b")
+	})
+	f.Run()
+
+	if spy.failed {
+		t.Error("Expected no failures in preview mode:\n" + f.output.String())
+	}
+	for _, expected := range []string{
+		"    ? Would fail (ShouldEqual 2)\n",
+		"    ? Would panic (",
+		"    ? Would also fail (shouldGrow)\n",
+		"    ? Would warn (ShouldStartWith \"b\")\n",
+	} {
+		if ok, message := So(f.output.String(), ShouldContainSubstring, expected); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+func TestParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+
+	var lock sync.Mutex
+	running, most, setups, teardowns, finished := 0, 0, 0, 0, 0
+	f.Setup(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		setups++
+		running++
+		if running > most {
+			most = running
+		}
+	})
+	f.Teardown(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		teardowns++
+		running--
+	})
+	for x := 0; x < 5; x++ {
+		f.Test(fmt.Sprint("B", x), func() {
+			time.Sleep(time.Millisecond * 10)
+			f.Log("    working\n")
+			lock.Lock()
+			defer lock.Unlock()
+			finished++
+		})
+	}
+	f.Test("Panics", func() { panic("boink") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(finished, ShouldEqual, 5); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(setups, ShouldEqual, 6); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(teardowns, ShouldEqual, 6); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(most, ShouldEqual, 2); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in test: [boink]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestParallelOutcomes(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(3)
+	f.Test("Passes", func() { f.So("One", 1, ShouldEqual, 1) })
+	f.Test("Fails", func() { f.So("One", 1, ShouldEqual, 2) })
+	f.GoTest("Fails elsewhere", func(done func()) {
+		go func() {
+			defer done()
+			f.So("Two", 2, ShouldEqual, 3)
+		}()
+	})
+	f.Run()
+
+	if ok, message := So(f.CompactSummary(), ShouldStartWith, "[FAIL] A (1/3, 2 failures, "); !ok {
+		t.Error("\n" + message)
+	}
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	var suite xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &suite); err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(suite.Failures, ShouldEqual, 2); !ok {
+		t.Error("\n" + message)
+	}
+	for _, test := range suite.Cases {
+		if failed := test.Failure != nil; failed != strings.HasPrefix(test.Name, "Fails") {
+			t.Errorf("Unexpected outcome of %q: %+v", test.Name, test.Failure)
+		}
+	}
+}
+
+func TestParallelSetupOncePanics(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	f.SetupOnce(func() { panic("boink") })
+	f.Test("B1", func() {})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in setup: [boink]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Timeout(time.Millisecond * 20)
+	hang := make(chan struct{})
+	defer close(hang)
+	b2 := false
+	f.GoTest("Hangs", func(done func()) {
+		go func() {
+			defer done()
+			<-hang
+		}()
+	})
+	f.Test("Finishes", func() { b2 = true })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(b2, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "TIMEOUT: \"Hangs\" exceeded 20ms"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.CompactSummary(), ShouldStartWith, "[FAIL] A (1/2, 1 failures, "); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	var calls []string
+	f.Teardown(func() { calls = append(calls, "teardown") })
+	f.Test("B1", func() {
+		f.Cleanup(func() { calls = append(calls, "first") })
+		f.Cleanup(func() { panic("boink") })
+		f.Cleanup(func() { calls = append(calls, "third") })
+		panic("test")
+	})
+	f.Test("B2", func() {
+		calls = append(calls, "B2")
+	})
+	f.Run()
+
+	if ok, message := So(calls, ShouldResemble, []string{"teardown", "third", "first", "B2", "teardown"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in cleanup: [boink]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestCleanupInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(3)
+	var lock sync.Mutex
+	calls := map[string][]string{}
+	record := func(description, call string) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls[description] = append(calls[description], call)
+	}
+	for x := 0; x < 3; x++ {
+		description := fmt.Sprint("B", x)
+		f.Test(description, func() {
+			f.Cleanup(func() { record(description, "first") })
+			f.Cleanup(func() { record(description, "second") })
+			time.Sleep(time.Millisecond)
+		})
+	}
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "outside of a test case"); !ok {
+		t.Error("\n" + message)
+	}
+	for x := 0; x < 3; x++ {
+		description := fmt.Sprint("B", x)
+		if ok, message := So(calls[description], ShouldResemble, []string{"second", "first"}); !ok {
+			t.Error(description + "\n" + message)
+		}
+	}
+}
+
+func TestFaultsInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	f.WithFault("timeout", func() {})
+	var lock sync.Mutex
+	faulted := map[string][]bool{}
+	for x := 0; x < 3; x++ {
+		description := fmt.Sprint("B", x)
+		f.Test(description, func() {
+			time.Sleep(time.Millisecond)
+			lock.Lock()
+			defer lock.Unlock()
+			faulted[description] = append(faulted[description], f.ShouldFault("timeout"))
+		})
+	}
+	f.Run()
+
+	for x := 0; x < 3; x++ {
+		description := fmt.Sprint("B", x)
+		if ok, message := So(faulted[description], ShouldResemble, []bool{false, true}); !ok {
+			t.Error(description + "\n" + message)
+		}
+	}
+}
+
+func TestOutputInParallel(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gounit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	f.RequireOutput(true)
+	f.OutputDir(directory)
+	f.Test("Silent", func() { time.Sleep(time.Millisecond) })
+	f.Test("Talkative", func() {
+		time.Sleep(time.Millisecond)
+		f.So("Passes", 1, ShouldEqual, 1)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "No output was produced by \"Silent\""); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "No output was produced by \"Talkative\""); !ok {
+		t.Error("\n" + message)
+	}
+
+	talkative, err := ioutil.ReadFile(filepath.Join(directory, "A", "Talkative.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(withoutDurations(string(talkative)), ShouldStartWith, " -> \"Talkative\"\n    + Passes\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(string(talkative), ShouldNotContainSubstring, "Silent"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestHeapGrowthInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	f.AssertHeapGrowthUnder(1)
+	ran := false
+	f.Test("B", func() { ran = true })
+	f.Run()
+
+	if ok, message := So(ran, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "AssertHeapGrowthUnder are not supported when running in parallel"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestTeardownWithResult(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	results := map[string]bool{}
+	current := ""
+	f.BeforeEachNamed(func(description string) { current = description })
+	f.TeardownWithResult(func(passed bool) { results[current] = passed })
+	f.Test("Passes", func() { f.So("One", 1, ShouldEqual, 1) })
+	f.Test("Fails", func() { f.So("One", 1, ShouldEqual, 2) })
+	f.Test("Panics", func() { panic("boink") })
+	f.Test("Passes again", func() {})
+	f.Run()
+
+	expected := map[string]bool{"Passes": true, "Fails": false, "Panics": false, "Passes again": true}
+	if ok, message := So(results, ShouldResemble, expected); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestTeardownWithResultInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	var lock sync.Mutex
+	var results []bool
+	f.TeardownWithResult(func(passed bool) {
+		lock.Lock()
+		defer lock.Unlock()
+		results = append(results, passed)
+	})
+	f.Test("Fails", func() { f.So("One", 1, ShouldEqual, 2) })
+	f.Test("Passes later", func() {
+		time.Sleep(10 * time.Millisecond)
+		f.So("One", 1, ShouldEqual, 1)
+	})
+	f.Run()
+
+	if ok, message := So(results, ShouldResemble, []bool{false, true}); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAddSetup(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	var calls []string
+	f.AddSetup(func() { calls = append(calls, "setup1") })
+	f.AddSetup(func() { calls = append(calls, "setup2") })
+	f.AddSetup(func() { calls = append(calls, "setup3") })
+	f.Test("B1", func() { calls = append(calls, "B1") })
+	f.Test("B2", func() { calls = append(calls, "B2") })
+	f.Run()
+
+	expected := []string{"setup1", "setup2", "setup3", "B1", "setup1", "setup2", "setup3", "B2"}
+	if ok, message := So(calls, ShouldResemble, expected); !ok {
+		t.Error("\n" + message)
+	}
+
+	calls = nil
+	f = NewFixture("A", spy)
+	f.AddSetup(func() { calls = append(calls, "setup1") })
+	f.Setup(func() { calls = append(calls, "setup2") })
+	f.Test("B1", func() { calls = append(calls, "B1") })
+	f.Run()
+
+	if ok, message := So(calls, ShouldResemble, []string{"setup2", "B1"}); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestBeforeAllAfterAll(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	var calls []string
+	f.BeforeAll(func() { calls = append(calls, "before all") })
+	f.AfterAll(func() { calls = append(calls, "after all") })
+	f.Setup(func() { calls = append(calls, "setup") })
+	f.Teardown(func() { calls = append(calls, "teardown") })
+	f.Test("B1", func() { calls = append(calls, "B1") })
+	f.Test("B2", func() { panic("boink") })
+	f.Run()
+
+	expected := []string{"before all", "setup", "B1", "teardown", "setup", "teardown", "after all"}
+	if ok, message := So(calls, ShouldResemble, expected); !ok {
+		t.Error("\n" + message)
+	}
+
+	calls = nil
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.BeforeAll(func() { panic("no database") })
+	f.AfterAll(func() { calls = append(calls, "after all") })
+	f.Test("B1", func() { calls = append(calls, "B1") })
+	f.Run()
+
+	if ok, message := So(calls, ShouldResemble, []string{"after all"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in BeforeAll: [no database]"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.AfterAll(func() { panic("boink") })
+	f.Test("B1", func() {})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in AfterAll: [boink]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestDurations(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Teardown(func() { time.Sleep(time.Millisecond * 5) })
+	f.Test("Slow", func() { time.Sleep(time.Millisecond * 20) })
+	f.Test("Fast", func() {})
+	f.SkipTest("Skipped", func() {})
+	f.Run()
+
+	durations := f.Durations()
+	if ok, message := So(len(durations), ShouldEqual, 3); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(durations["Slow"], ShouldBeGreaterThanOrEqualTo, time.Millisecond*25); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(durations["Fast"], ShouldBeGreaterThanOrEqualTo, time.Millisecond*5); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(durations["Skipped"], ShouldEqual, 0); !ok {
+		t.Error("\n" + message)
+	}
+	line := regexp.MustCompile(` -> "Slow" \((.+)\)\n`).FindStringSubmatch(f.output.String())
+	if ok, message := So(line, ShouldNotBeEmpty); !ok {
+		t.Fatal("\n" + message)
+	}
+	if ok, message := So(line[1], ShouldEqual, durations["Slow"].Round(time.Microsecond).String()); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestDurationsInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	f.Test("Slow", func() { time.Sleep(time.Millisecond * 20) })
+	f.Test("Fast", func() {})
+	f.Run()
+
+	durations := f.Durations()
+	if ok, message := So(durations["Slow"], ShouldBeGreaterThanOrEqualTo, time.Millisecond*20); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> \"Slow\"\n"); !ok {
+		t.Error("\n" + message)
+	}
+	line := regexp.MustCompile(` <- "Slow" \((.+)\)\n`).FindStringSubmatch(f.output.String())
+	if ok, message := So(line, ShouldNotBeEmpty); !ok {
+		t.Fatal("\n" + message)
+	}
+	if ok, message := So(line[1], ShouldEqual, durations["Slow"].Round(time.Microsecond).String()); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+// withoutDurations removes the durations appended to the line of each test
+// case (see Durations) so that output can be compared verbatim.
+func withoutDurations(output string) string {
+	return durationSuffix.ReplaceAllString(output, "\n")
+}
+
+var durationSuffix = regexp.MustCompile(` \([0-9][0-9.hmµns]*\)\n`)
+
+func TestAssert(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	torndown, after, b2 := false, false, false
+	f.Teardown(func() { torndown = true })
+	f.Test("B1", func() {
+		f.Assert("Passes", 1, ShouldEqual, 1)
+		f.Assert("Fails", 1, ShouldEqual, 2)
+		after = true
+		f.So("Never checked", 1, ShouldEqual, 3)
+	})
+	f.Test("B2", func() { b2 = true })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(after, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(torndown, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(b2, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "Never checked"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "PANIC"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "gounit_test.go:"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoReportsWhetherTheAssertionPassed(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	var passed, failed, skipped bool
+	f.Test("B1", func() {
+		passed = f.So("Passes", 1, ShouldEqual, 1)
+		var pointer *struct{ Name string }
+		if failed = f.So("Fails", pointer, ShouldNotBeNil); !failed {
+			return
+		}
+		f.So("Not reached", pointer.Name, ShouldEqual, "")
+	})
+	f.Test("B2", func() { skipped = f.SkipSo("Skipped", 1, ShouldEqual, 2) })
+	f.Run()
+
+	if ok, message := So(passed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(skipped, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "PANIC"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestExpectAssertions(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.ExpectAssertions("Exact", 2)
+	f.ExpectAssertions("Short-circuited", 2)
+	f.Test("Exact", func() {
+		f.So("One", 1, ShouldEqual, 1)
+		f.SkipSo("Two", 2, ShouldEqual, 2)
+	})
+	found := false
+	f.Test("Short-circuited", func() {
+		if !found {
+			return
+		}
+		f.So("One", 1, ShouldEqual, 1)
+		f.So("Two", 2, ShouldEqual, 2)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Expected 2 assertions in \"Short-circuited\" (but 0 were made; see ExpectAssertions)."); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "assertions in \"Exact\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestExpectAssertionsInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(4)
+	for x := 0; x < 4; x++ {
+		description := fmt.Sprint("B", x)
+		f.ExpectAssertions(description, 3)
+		f.Test(description, func() {
+			for y := 0; y < 3; y++ {
+				f.So("Passes", y, ShouldEqual, y)
+			}
+		})
+	}
+	f.ExpectAssertions("Short", 3)
+	f.Test("Short", func() { f.So("Passes", 1, ShouldEqual, 1) })
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Expected 3 assertions in \"Short\" (but 1 were made; see ExpectAssertions)."); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(strings.Count(f.output.String(), "Expected 3 assertions"), ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestExpectAssertionsWhilePreviewing(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.PreviewAssertions(true)
+	f.WarnWithoutAssertions(true)
+	f.ExpectAssertions("B1", 2)
+	f.Test("B1", func() {
+		f.So("One", 1, ShouldEqual, 1)
+		f.So("Two", 2, ShouldEqual, 2)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "assertions in"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "WARNING"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestWarnWithoutAssertions(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.WarnWithoutAssertions(true)
+	f.Test("Asserts", func() { f.So("One", 1, ShouldEqual, 1) })
+	f.Test("Forgot", func() {})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "WARNING: \"Forgot\" made no assertions"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "WARNING: \"Asserts\""); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.CompactSummary(), ShouldStartWith, "[PASS] A (2/2, 1 warning, "); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSkipTestReason(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	executed := false
+	f.SkipTestReason("B1", "waiting on the new API", func() { executed = true })
+	f.SkipGoTestReason("B2", "flaky on CI", func(done func()) { executed = true; done() })
+	f.SkipTest("B3", func() { executed = true })
+	f.Test("B4", func() {})
+	f.Run()
+
+	if ok, message := So(executed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	for _, expected := range []string{
+		" -> (skipped: waiting on the new API) \"B1\"\n",
+		" -> (skipped: flaky on CI) \"B2\"\n",
+		" -> (skipped) \"B3\"\n",
+	} {
+		if ok, message := So(f.output.String(), ShouldContainSubstring, expected); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+func TestSkipNow(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	torndown, b2 := false, false
+	f.Teardown(func() { torndown = true })
+	f.Test("B1", func() {
+		f.SkipNow("the feature flag is off")
+		f.So("Would fail", 1, ShouldEqual, 2)
+	})
+	f.Test("B2", func() { b2 = true })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.spoiled, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(torndown, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(b2, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "Would fail"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "    (skipped: the feature flag is off)\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "  - \"B1\" (the feature flag is off)\n"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSkipNowInParallel(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Parallel(2)
+	f.Test("B1", func() { f.SkipNow("one") })
+	f.Test("B2", func() { f.SkipNow("two") })
+	f.Test("B3", func() { f.So("Passes", 1, ShouldEqual, 1) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "  - \"B1\" (one)\n  - \"B2\" (two)\n"); !ok {
+		t.Error("\n" + message)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(buffer.String(), ShouldContainSubstring, "<skipped message=\"two\"></skipped>"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.FailFast()
+	teardowns, afterAll := 0, false
+	b1, b3 := false, false
+	f.Teardown(func() { teardowns++ })
+	f.AfterAll(func() { afterAll = true })
+	f.Test("B1", func() { b1 = true })
+	f.Test("B2", func() { f.So("Fails", 1, ShouldEqual, 2) })
+	f.Test("B3", func() { b3 = true })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(b1, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(b3, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(teardowns, ShouldEqual, 2); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(afterAll, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> (not run, fail-fast) \"B3\"\n"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestHelper(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	var line int
+	f.Test("B1", func() {
+		_, _, line, _ = runtime.Caller(0)
+		assertPositive(f, -1) // reported here.
+	})
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, fmt.Sprintf("gounit_test.go:%d\n", line+1)); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { line = assertNegative(f, 1) })
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, fmt.Sprintf("gounit_test.go:%d\n", line)); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func assertPositive(f *Fixture, n int) {
+	f.Helper()
+	assertGreater(f, n, 0)
+}
+
+func assertGreater(f *Fixture, n, m int) {
+	f.Helper()
+	f.So("Should be greater", n, ShouldBeGreaterThan, m)
+}
+
+// assertNegative isn't marked as a helper, so failures are reported within
+// (at the line it returns).
+func assertNegative(f *Fixture, n int) (line int) {
+	_, _, line, _ = runtime.Caller(0)
+	f.So("Should be negative", n, ShouldBeLessThan, 0)
+	return line + 1
+}
+
+func TestPanicLocation(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { panicIndirectly() })
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, fmt.Sprintf("gounit_test.go:%d\n", panicLine)); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "panicThroughAnother(...)"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.ShowStackTraces()
+	f.GoTest("B1", func(done func()) {
+		go func() {
+			defer done()
+			panicIndirectly()
+		}()
+	})
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, fmt.Sprintf("gounit_test.go:%d\n", panicLine)); !ok {
+		t.Error("\n" + message)
+	}
+	for _, expected := range []string{".panicHere(...)", ".panicThroughAnother(...)", ".panicIndirectly(...)"} {
+		if ok, message := So(f.output.String(), ShouldContainSubstring, expected); !ok {
+			t.Error("\n" + message)
+		}
+	}
+}
+
+// panicIndirectly panics through two other functions (see panicLine).
+func panicIndirectly() {
+	panicThroughAnother()
+}
+
+func panicThroughAnother() {
+	panicHere()
+}
+
+var panicLine int // panicLine is the line of the panic in panicHere.
+
+func panicHere() {
+	_, _, line, _ := runtime.Caller(0)
+	panicLine = line + 2
+	panic("boink")
+}
+
+func TestRunFilterFromEnvironment(t *testing.T) {
+	defer restoreEnv("GOUNIT_RUN")()
+	os.Setenv("GOUNIT_RUN", "^Match")
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	var executed []string
+	f.Test("Matches", func() { executed = append(executed, "Matches") })
+	f.Test("Does not match", func() { executed = append(executed, "Does not match") })
+	f.SkipTestReason("Skipped", "flaky", func() { executed = append(executed, "Skipped") })
+	f.Run()
+
+	if ok, message := So(executed, ShouldResemble, []string{"Matches"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> (skipped: not matched by GOUNIT_RUN) \"Does not match\"\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " -> (skipped: flaky) \"Skipped\"\n"); !ok {
+		t.Error("\n" + message)
+	}
+
+	executed = nil
+	f = NewFixture("A", new(spyT))
+	f.FocusTest("Matches (focused)", func() { executed = append(executed, "Matches (focused)") })
+	f.FocusTest("Focused", func() { executed = append(executed, "Focused") })
+	f.Test("Matches (not focused)", func() { executed = append(executed, "Matches (not focused)") })
+	f.Run()
+
+	if ok, message := So(executed, ShouldResemble, []string{"Matches (focused)"}); !ok {
+		t.Error("\n" + message)
+	}
+
+	os.Setenv("GOUNIT_RUN", "(unclosed")
+	spy = new(spyT)
+	executed = nil
+	f = NewFixture("A", spy)
+	f.Test("Matches", func() { executed = append(executed, "Matches") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(executed, ShouldBeEmpty); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Invalid GOUNIT_RUN pattern: error parsing regexp: missing closing ): `(unclosed`"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+// restoreEnv returns a func that restores the environment variable to its
+// current value (or lack thereof).
+func restoreEnv(name string) func() {
+	value, found := os.LookupEnv(name)
+	return func() {
+		if found {
+			os.Setenv(name, value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestGoTestPanicsBeforeDone(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	torndown, b2 := false, false
+	f.Teardown(func() { torndown = true })
+	f.GoTest("B1", func(done func()) {
+		panic("boink")
+	})
+	f.Test("B2", func() { b2 = true })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "PANIC in test: [boink]"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "PANIC in setup"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(torndown, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(b2, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestFixtureDisabledAfterRun(t *testing.T) {
+	spy := new(spyT)
+
+	f := NewFixture("A", spy)
+	f.Test("This runs", func() {})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error(message)
+	}
+
+	f.Test("This doesn't run", func() { f.So("really, this doesn't run", true, ShouldBeFalse) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error(message)
+	}
+}
+
+func TestSeededRand(t *testing.T) {
+	sample := func() []int64 {
+		var values []int64
+		f := NewFixture("A", new(spyT))
+		f.WithSeed(42)
+		f.Test("B1", func() { values = append(values, f.Rand().Int63(), f.Rand().Int63()) })
+		f.Test("B2", func() { values = append(values, f.Rand().Int63(), f.Rand().Int63()) })
+		f.Run()
+		return values
+	}
+
+	first, second := sample(), sample()
+
+	if ok, message := So(first, ShouldResemble, second); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(first[:2], ShouldResemble, first[2:]); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	passing := NewFixture("A", new(spyT))
+	passing.Test("B1", func() {})
+	passing.Run()
+
+	if ok, message := So(passing.ExitCode(), ShouldEqual, 0); !ok {
+		t.Error("\n" + message)
+	}
+
+	failing := NewFixture("A", new(spyT))
+	failing.Test("B1", func() { failing.So("fail", true, ShouldBeFalse) })
+	failing.Run()
+
+	if ok, message := So(failing.ExitCode(), ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+
+	spoiled := NewFixture("", new(spyT))
+	spoiled.Test("B1", func() {})
+	spoiled.Run()
+
+	if ok, message := So(spoiled.ExitCode(), ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestInlineResults(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.InlineResults(true)
+	f.Test("B1", func() {
+		f.So("passes", true, ShouldBeTrue)
+		f.So("fails", true, ShouldBeFalse)
+	})
+	f.Run()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "    ✓ passes\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "    ✗ fails\n"); !ok {
+		t.Error("\n" + message)
 	}
 }
 
@@ -0,0 +1,47 @@
+package gounit
+
+import "sync"
+
+// registry collects facts about fixtures that are only meaningful across
+// the whole package (see CheckNoFocus).
+var registry = struct {
+	sync.Mutex
+	focused []string // descriptions of fixtures that ran with focused tests.
+	todo    []string // descriptions of fixtures marked as TODO.
+}{}
+
+func registerFocused(description string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.focused = append(registry.focused, description)
+}
+
+// CheckNoFocus fails t if any fixture run so far had focused test cases
+// (see FocusTest and FocusGoTest). Call it after all fixtures have run
+// (from TestMain or a final test) to keep focused tests out of CI.
+func CheckNoFocus(t T) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, description := range registry.focused {
+		t.Log("Fixture ran with focused tests: '" + description + "'")
+	}
+	if len(registry.focused) > 0 {
+		t.Fail()
+	}
+}
+
+func registerTODO(description string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.todo = append(registry.todo, description)
+}
+
+// TODOs returns the descriptions of the fixtures run so far that were marked
+// as pending (see Fixture.TODO). Call it from TestMain (after m.Run) to
+// report how many planned test suites remain unimplemented.
+func TODOs() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	return append([]string(nil), registry.todo...)
+}
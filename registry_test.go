@@ -0,0 +1,52 @@
+package gounit
+
+import "testing"
+
+func TestCheckNoFocus(t *testing.T) {
+	registry.focused = nil
+
+	spy := new(spyT)
+	CheckNoFocus(spy)
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	f := NewFixture("Focused fixture", new(spyT))
+	f.Test("B1", func() {})
+	f.FocusTest("B2", func() {})
+	f.Run()
+
+	spy = new(spyT)
+	CheckNoFocus(spy)
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(registry.focused, ShouldContain, "Focused fixture"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestTODO(t *testing.T) {
+	registry.todo = nil
+
+	spy := new(spyT)
+	f := NewFixture("Pending fixture", spy)
+	f.TODO("waiting on the new parser")
+	f.Test("B1", func() { t.Error("TODO fixtures should not run any tests.") })
+	f.Run()
+
+	if ok, message := So(spy.skipped, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "TODO: waiting on the new parser"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(TODOs(), ShouldResemble, []string{"Pending fixture"}); !ok {
+		t.Error("\n" + message)
+	}
+}
@@ -0,0 +1,347 @@
+package gounit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// outcome is the structured record of a single test case, kept for
+// reporters (as opposed to the human-readable output).
+type outcome struct {
+	description string
+	group       string
+	quarantined bool
+	skipped     bool   // skipped records that the test case called SkipNow,
+	reason      string // and reason the reason it gave.
+	failed      bool
+	assertions  int // assertions counts the assertions made (see ExpectAssertions).
+	failures    []string
+	reports     []string // reports are the failures as they were logged.
+	attachments []attachment
+	cleanups    []func()      // cleanups are run (last first) after the teardown (see Cleanup).
+	output      *bytes.Buffer // output is what the test case logged (only kept when running in parallel).
+	duration    time.Duration // duration includes setup, teardown, and cleanups.
+}
+
+type attachment struct {
+	name    string
+	content []byte
+}
+
+// CompactSummary returns a single grep-able line summarizing the fixture,
+// like `[PASS] A (7/7, 12ms)` or `[FAIL] A (5/7, 2 failures, 30ms)`, where
+// the counts are of passing and executed test cases (followed by the number
+// of warnings, if any; see Warn). Call it after Run.
+func (self *Fixture) CompactSummary() string {
+	failures := 0
+	for _, outcome := range self.outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+	passed := len(self.outcomes) - failures
+	milliseconds := self.elapsed.Milliseconds()
+
+	warnings := ""
+	if self.warnings == 1 {
+		warnings = "1 warning, "
+	} else if self.warnings > 1 {
+		warnings = fmt.Sprintf("%d warnings, ", self.warnings)
+	}
+
+	if !self.failed && !self.spoiled {
+		return fmt.Sprintf("[PASS] %s (%d/%d, %s%dms)", self.description, passed, len(self.outcomes), warnings, milliseconds)
+	}
+	return fmt.Sprintf("[FAIL] %s (%d/%d, %d failures, %s%dms)", self.description, passed, len(self.outcomes), failures, warnings, milliseconds)
+}
+
+// Attach associates an artifact (a screenshot, a dump, etc.) with the test
+// case currently running so that it can be surfaced by structured reports
+// (see WriteXML). If an OutputDir is set, the artifact is also written to
+// `path/<fixture>/<test>-<name>`. Attach must be called from within a test
+// case.
+func (self *Fixture) Attach(name string, content []byte) {
+	self.lock.Lock()
+	current := self.inProgress()
+	if current != nil {
+		current.attachments = append(current.attachments, attachment{name: name, content: content})
+	}
+	self.lock.Unlock()
+
+	if current == nil {
+		self.Logf("    Attach(\"%s\") was called outside of a test case.\n", name)
+		return
+	}
+	self.Logf("    (attached: \"%s\", %d bytes)\n", name, len(content))
+	self.writeAttachment(current.description, name, content)
+}
+
+// writeAttachment writes the content of an attachment to its file (see
+// OutputDir and Attach).
+func (self *Fixture) writeAttachment(description, name string, content []byte) {
+	if self.outputDir == "" {
+		return
+	}
+	path := self.attachmentPath(description, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		self.Logf("    Could not write the attachment \"%s\" to a file: %s\n", name, err)
+	}
+}
+
+// attachmentPath is where an attachment is written (see OutputDir) and how
+// WriteXML refers to it.
+func (self *Fixture) attachmentPath(description, name string) string {
+	return filepath.Join(self.outputDir, fileName(self.description), fileName(description)+"-"+fileName(name))
+}
+
+// SetNameSanitizer registers a function used to clean up fixture and test
+// descriptions before they are used as names in structured (XML/JSON)
+// reports. The original descriptions are still used in the regular output.
+// The default sanitizer removes control characters.
+func (self *Fixture) SetNameSanitizer(sanitizer func(string) string) {
+	if self.frozen {
+		return
+	}
+	self.sanitizer = sanitizer
+}
+
+// name returns the description as it should appear in structured reports.
+func (self *Fixture) name(description string) string {
+	if self.sanitizer == nil {
+		return sanitizeName(description)
+	}
+	return self.sanitizer(description)
+}
+
+func sanitizeName(description string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, description)
+}
+
+// AssertAllRan fails the fixture if any registered test case that should
+// have run (one that wasn't skipped or excluded by a focused test case) did
+// not execute, for example because something (including FailFast) stopped
+// Run part way through. Call it after Run (with `defer`, register it before
+// Run).
+func (self *Fixture) AssertAllRan() {
+	if !self.started {
+		return // the whole fixture was skipped.
+	}
+	executed := make(map[string]bool)
+	for _, outcome := range self.outcomes {
+		executed[outcome.description] = true
+	}
+	var missing []string
+	for description := range self.tests {
+		if executed[description] {
+			continue
+		}
+		if _, skipped := self.skipped[description]; skipped {
+			continue
+		}
+		if _, focus := self.focused[description]; len(self.focused) > 0 && !focus {
+			continue
+		}
+		missing = append(missing, description)
+	}
+	if len(missing) == 0 {
+		return
+	}
+	sort.Strings(missing)
+
+	self.fail()
+	report := new(strings.Builder)
+	fmt.Fprintf(report, "%d of %d registered tests did not run:\n", len(missing), len(self.tests))
+	for _, description := range missing {
+		fmt.Fprintf(report, "  - \"%s\"\n", description)
+	}
+	self.Log(report.String())
+	self.t.Log(report.String()) // the rest of the output has already been dumped.
+}
+
+// OutputDir causes the output of each test case to also be written to
+// `path/<fixture>/<test>.log` (with both names reduced to characters that
+// are safe in file names). Directories are created as needed. Pass the
+// result of t.TempDir() to have the files removed automatically.
+func (self *Fixture) OutputDir(path string) {
+	if self.frozen {
+		return
+	}
+	self.outputDir = path
+}
+
+// writeOutputFile writes the content logged by a test case to its file (see
+// OutputDir).
+func (self *Fixture) writeOutputFile(description string, content []byte) {
+	if self.outputDir == "" {
+		return
+	}
+	directory := filepath.Join(self.outputDir, fileName(self.description))
+	err := os.MkdirAll(directory, 0755)
+	if err == nil {
+		path := filepath.Join(directory, fileName(description)+".log")
+		err = ioutil.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		self.Logf("    Could not write the output of \"%s\" to a file: %s\n", description, err)
+	}
+}
+
+// fileName replaces any characters of name that aren't safe in file names.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// WriteXML writes a JUnit/XUnit-style XML report of the fixture to w, with a
+// <testcase> for each test case that was executed or skipped, in the order
+// they were registered (along with its duration, any failures as they were
+// logged, and the reason it was skipped, if known). Each attachment (see
+// Attach) is listed in <system-out>: as an `[[ATTACHMENT|path]]` line if it
+// was written to the OutputDir, or else embedded as base64. Call it after Run.
+func (self *Fixture) WriteXML(w io.Writer) error {
+	suite := xmlSuite{Name: self.name(self.description), Time: seconds(self.elapsed)}
+	outcomes := make(map[string]*outcome, len(self.outcomes))
+	for _, outcome := range self.outcomes {
+		outcomes[outcome.description] = outcome
+	}
+	for _, description := range self.order { // registration order, not (parallel) execution order.
+		outcome, executed := outcomes[description]
+		if !executed {
+			suite.Cases = append(suite.Cases, self.unexecutedCase(description))
+			continue
+		}
+		suite.Cases = append(suite.Cases, self.executedCase(outcome))
+		for _, fault := range self.faults {
+			if variant, executed := outcomes[description+" [fault: "+fault.name+"]"]; executed {
+				suite.Cases = append(suite.Cases, self.executedCase(variant))
+			}
+		}
+	}
+	for _, test := range suite.Cases {
+		if test.Failure != nil {
+			suite.Failures++
+		}
+		if test.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// executedCase reports the outcome of a test case that was executed.
+func (self *Fixture) executedCase(outcome *outcome) xmlCase {
+	test := xmlCase{
+		Name:      self.name(outcome.description),
+		ClassName: self.className(outcome.group),
+		Time:      seconds(outcome.duration),
+	}
+	if outcome.skipped {
+		test.Skipped = &xmlSkipped{Message: outcome.reason}
+	}
+	if outcome.failed {
+		test.Failure = &xmlFailure{
+			Message: strings.Join(outcome.failures, "\n"),
+			Text:    strings.Join(outcome.reports, ""),
+		}
+	}
+	for _, attachment := range outcome.attachments {
+		if self.outputDir != "" {
+			test.SystemOut += fmt.Sprintf("[[ATTACHMENT|%s]]\n", self.attachmentPath(outcome.description, attachment.name))
+		} else {
+			test.SystemOut += fmt.Sprintf("%s (base64): %s\n", attachment.name, base64.StdEncoding.EncodeToString(attachment.content))
+		}
+	}
+	return test
+}
+
+// unexecutedCase reports a test case that was skipped without being
+// executed, along with the reason (if known).
+func (self *Fixture) unexecutedCase(description string) xmlCase {
+	reason := self.skipped[description]
+	if _, focus := self.focused[description]; len(self.focused) > 0 && !focus {
+		reason = "not focused"
+	} else if _, stopped := self.stopped[description]; stopped {
+		reason = "not run, fail-fast"
+	}
+	return xmlCase{
+		Name:      self.name(description),
+		ClassName: self.className(self.groups[description]),
+		Time:      seconds(0),
+		Skipped:   &xmlSkipped{Message: reason},
+	}
+}
+
+// className identifies the fixture (and group, if any; see Group) of a test
+// case in an XML report.
+func (self *Fixture) className(group string) string {
+	if group == "" {
+		return self.name(self.description)
+	}
+	return self.name(self.description + "/" + group)
+}
+
+func seconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+type xmlSuite struct {
+	XMLName  xml.Name  `xml:"testsuite"`
+	Name     string    `xml:"name,attr"`
+	Tests    int       `xml:"tests,attr"`
+	Failures int       `xml:"failures,attr"`
+	Skipped  int       `xml:"skipped,attr"`
+	Time     string    `xml:"time,attr"`
+	Cases    []xmlCase `xml:"testcase"`
+}
+
+type xmlCase struct {
+	Name      string      `xml:"name,attr"`
+	ClassName string      `xml:"classname,attr"`
+	Time      string      `xml:"time,attr"`
+	Failure   *xmlFailure `xml:"failure,omitempty"`
+	Skipped   *xmlSkipped `xml:"skipped,omitempty"`
+	SystemOut string      `xml:"system-out,omitempty"`
+}
+
+type xmlFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type xmlSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
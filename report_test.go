@@ -0,0 +1,355 @@
+package gounit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNameSanitizer(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+
+	if ok, message := So(f.name("bell\a and\x00 null"), ShouldEqual, "bell and null"); !ok {
+		t.Error("\n" + message)
+	}
+
+	f.SetNameSanitizer(strings.ToUpper)
+
+	if ok, message := So(f.name("custom"), ShouldEqual, "CUSTOM"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestNameSanitizerInXML(t *testing.T) {
+	f := NewFixture("A\x1b[0m", new(spyT))
+	f.Group("G\x07", func() {
+		f.Test("bell\a and\x00 null", func() {})
+	})
+	f.Run()
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	var report xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, message := So(report.Name, ShouldEqual, "A[0m"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(report.Cases[0].Name, ShouldEqual, "bell and null"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(report.Cases[0].ClassName, ShouldEqual, "A[0m/G"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestCustomNameSanitizerInXML(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.SetNameSanitizer(func(name string) string { return strings.ReplaceAll(name, " ", "_") })
+	f.Group("My Group", func() {
+		f.Test("My Test", func() {})
+	})
+	f.Run()
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	var report xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, message := So(report.Cases[0].Name, ShouldEqual, "My_Test"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(report.Cases[0].ClassName, ShouldEqual, "A/My_Group"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "\"My Test\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.Test("B1", func() { f.Attach("dump.txt", []byte("GOPHERS!")) })
+	f.Test("B2", func() {})
+	f.Run()
+
+	attachments := map[string][]attachment{}
+	for _, outcome := range f.outcomes {
+		attachments[outcome.description] = outcome.attachments
+	}
+
+	if ok, message := So(attachments["B1"], ShouldResemble, []attachment{{name: "dump.txt", content: []byte("GOPHERS!")}}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(attachments["B2"], ShouldBeEmpty); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "(attached: \"dump.txt\", 8 bytes)"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAttachmentsInXML(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.Test("B1", func() {
+		f.Attach("dump.txt", []byte("GOPHERS!"))
+		f.Attach("screen.png", []byte{0x89})
+	})
+	f.Test("B2", func() {})
+	f.Run()
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	var report xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, message := So(report.Cases[0].SystemOut, ShouldEqual, "dump.txt (base64): R09QSEVSUyE=\nscreen.png (base64): iQ==\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(report.Cases[1].SystemOut, ShouldBeEmpty); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAttachmentsInOutputDir(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gounit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	f := NewFixture("My Fixture", new(spyT))
+	f.OutputDir(directory)
+	f.Test("B1", func() { f.Attach("dump.txt", []byte("GOPHERS!")) })
+	f.Run()
+
+	path := filepath.Join(directory, "My_Fixture", "B1-dump_txt")
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(string(content), ShouldEqual, "GOPHERS!"); !ok {
+		t.Error("\n" + message)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	var report xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(report.Cases[0].SystemOut, ShouldEqual, "[[ATTACHMENT|"+path+"]]\n"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestCompactSummary(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.Test("B1", func() {})
+	f.Test("B2", func() {})
+	f.SkipTest("B3", func() {})
+	f.Run()
+
+	if ok, message := So(f.CompactSummary(), ShouldStartWith, "[PASS] A (2/2, "); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.CompactSummary(), ShouldEndWith, "ms)"); !ok {
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", new(spyT))
+	f.Test("B1", func() {})
+	f.Test("B2", func() { f.So("Fails", 1, ShouldEqual, 2) })
+	f.Test("B3", func() { panic("fails") })
+	f.Run()
+
+	if ok, message := So(f.CompactSummary(), ShouldStartWith, "[FAIL] A (1/3, 2 failures, "); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gounit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	f := NewFixture("My Fixture", new(spyT))
+	f.OutputDir(directory)
+	f.Test("B1: passes", func() { f.So("Passes", 1, ShouldEqual, 1) })
+	f.Test("B2/fails", func() { f.So("Fails", 1, ShouldEqual, 2) })
+	f.Run()
+
+	passing, err := ioutil.ReadFile(filepath.Join(directory, "My_Fixture", "B1__passes.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(withoutDurations(string(passing)), ShouldEqual, " -> \"B1: passes\"\n    + Passes\n"); !ok {
+		t.Error("\n" + message)
+	}
+
+	failing, err := ioutil.ReadFile(filepath.Join(directory, "My_Fixture", "B2_fails.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(withoutDurations(string(failing)), ShouldStartWith, " -> \"B2/fails\"\n    + Fails\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(string(failing), ShouldContainSubstring, "FAILED: \"Fails\""); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestAssertAllRan(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {})
+	f.SkipTest("B2", func() {})
+	f.Run()
+	f.AssertAllRan()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	setups := 0
+	f.Setup(func() {
+		setups++
+		if setups == 1 {
+			runtime.Goexit() // like t.FailNow, aborts Run part way through.
+		}
+	})
+	f.Test("B1", func() {})
+	f.Test("B2", func() {})
+	f.Test("B3", func() {})
+	f.SkipTest("B4", func() {})
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		f.Run()
+	}()
+	<-finished
+	f.AssertAllRan()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "2 of 4 registered tests did not run:"); !ok {
+		t.Error("\n" + message)
+	}
+
+	f = NewFixture("A", new(spyT))
+	f.FailFast()
+	f.Test("B1", func() { f.So("Fails", 1, ShouldEqual, 2) })
+	f.Test("B2", func() {})
+	f.Run()
+	f.AssertAllRan()
+
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "1 of 2 registered tests did not run:\n  - \"B2\"\n"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestWriteXML(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("Passes", func() {})
+	f.Test("Fails", func() { f.So("One", 1, ShouldEqual, 2) })
+	f.SkipTest("Skipped", func() {})
+	f.Group("G", func() {
+		f.Test("Grouped", func() {})
+	})
+	f.Run()
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(buffer.String(), ShouldStartWith, xml.Header+"<testsuite name=\"A\" tests=\"4\" failures=\"1\" skipped=\"1\" time=\""); !ok {
+		t.Error("\n" + message)
+	}
+
+	var suite xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &suite); err != nil {
+		t.Fatal(err)
+	}
+	if ok, message := So(len(suite.Cases), ShouldEqual, 4); !ok {
+		t.Fatal("\n" + message)
+	}
+	passes, fails, skipped, grouped := suite.Cases[0], suite.Cases[1], suite.Cases[2], suite.Cases[3]
+	if ok, message := So(passes.Name, ShouldEqual, "Passes"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(passes.Failure, ShouldBeNil); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(fails.Failure.Message, ShouldEqual, "Expected: '2'\nActual:   '1'\n(Should be equal)"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(fails.Failure.Text, ShouldContainSubstring, "FAILED: \"One\""); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(grouped.ClassName, ShouldEqual, "A/G"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(skipped.Name, ShouldEqual, "Skipped"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(skipped.Skipped, ShouldNotBeNil); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestWriteXMLInRegistrationOrder(t *testing.T) {
+	f := NewFixture("A", new(spyT))
+	f.WithFault("timeout", func() {})
+	f.Group("G", func() {
+		f.Test("B1", func() {})
+	})
+	f.SkipTest("B2", func() {})
+	f.Test("B3", func() {})
+	f.Run()
+
+	buffer := new(bytes.Buffer)
+	if err := f.WriteXML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	var suite xmlSuite
+	if err := xml.Unmarshal(buffer.Bytes(), &suite); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, test := range suite.Cases {
+		names = append(names, test.Name)
+	}
+	if ok, message := So(names, ShouldResemble, []string{"B1", "B1 [fault: timeout]", "B2", "B3", "B3 [fault: timeout]"}); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(suite.Skipped, ShouldEqual, 1); !ok {
+		t.Error("\n" + message)
+	}
+}
@@ -0,0 +1,1524 @@
+package gounit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/smartystreets/assertions"
+)
+
+// The SoXxx methods in this file are conveniences for assertions that don't
+// fit neatly into the signature shared by So and the ShouldXxx functions.
+// Each is a thin wrapper that passes a purpose-built (unexported) assertion
+// to the same machinery used by So.
+
+// SoAllValues asserts that every value in the map m satisfies the predicate.
+// On failure the key of the offending value is reported.
+func (self *Fixture) SoAllValues(description string, m interface{}, predicate func(interface{}) bool) {
+	self.so(description, m, shouldHaveAllValues, predicate)
+}
+
+// SoAllKeys asserts that every key in the map m satisfies the predicate.
+// On failure the offending key is reported.
+func (self *Fixture) SoAllKeys(description string, m interface{}, predicate func(interface{}) bool) {
+	self.so(description, m, shouldHaveAllKeys, predicate)
+}
+
+func shouldHaveAllValues(actual interface{}, expected ...interface{}) string {
+	m, predicate, fail := mapAndPredicate(actual, expected)
+	if fail != success {
+		return fail
+	}
+	for _, key := range sortedKeys(m) {
+		if value := m.MapIndex(key).Interface(); !predicate(value) {
+			return fmt.Sprintf(shouldHaveSatisfiedPredicateValue, key.Interface(), value)
+		}
+	}
+	return success
+}
+
+func shouldHaveAllKeys(actual interface{}, expected ...interface{}) string {
+	m, predicate, fail := mapAndPredicate(actual, expected)
+	if fail != success {
+		return fail
+	}
+	for _, key := range sortedKeys(m) {
+		if !predicate(key.Interface()) {
+			return fmt.Sprintf(shouldHaveSatisfiedPredicateKey, key.Interface())
+		}
+	}
+	return success
+}
+
+func mapAndPredicate(actual interface{}, expected []interface{}) (reflect.Value, func(interface{}) bool, string) {
+	if fail := need(1, expected); fail != success {
+		return reflect.Value{}, nil, fail
+	}
+	predicate, ok := expected[0].(func(interface{}) bool)
+	if !ok {
+		return reflect.Value{}, nil, fmt.Sprintf(shouldUsePredicate, expected[0])
+	}
+	m := reflect.ValueOf(actual)
+	if m.Kind() != reflect.Map {
+		return reflect.Value{}, nil, fmt.Sprintf(shouldHaveBeenAMap, actual)
+	}
+	return m, predicate, success
+}
+
+// SoReadersEqual reads both readers to completion and asserts that they
+// produced identical bytes. On failure the first differing offset is
+// reported along with a few bytes of context from each stream.
+func (self *Fixture) SoReadersEqual(description string, a, b io.Reader) {
+	self.so(description, a, shouldReadEqual, b)
+}
+
+func shouldReadEqual(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	a, aOK := actual.(io.Reader)
+	b, bOK := expected[0].(io.Reader)
+	if !aOK || !bOK {
+		return fmt.Sprintf(shouldHaveBeenAReader, actual, expected[0])
+	}
+	actualBytes, err := ioutil.ReadAll(a)
+	if err != nil {
+		return fmt.Sprintf(shouldHaveReadWithoutError, "actual", err)
+	}
+	expectedBytes, err := ioutil.ReadAll(b)
+	if err != nil {
+		return fmt.Sprintf(shouldHaveReadWithoutError, "expected", err)
+	}
+	if bytes.Equal(actualBytes, expectedBytes) {
+		return success
+	}
+	offset := 0
+	for offset < len(actualBytes) && offset < len(expectedBytes) && actualBytes[offset] == expectedBytes[offset] {
+		offset++
+	}
+	return fmt.Sprintf(shouldHaveReadIdentically, offset, context(expectedBytes, offset), context(actualBytes, offset))
+}
+
+// context returns up to 8 bytes of data surrounding the offset.
+func context(data []byte, offset int) []byte {
+	const radius = 4
+	start, end := offset-radius, offset+radius
+	if start < 0 {
+		start = 0
+	}
+	if end > len(data) {
+		end = len(data)
+	}
+	if start > end {
+		start = end
+	}
+	return data[start:end]
+}
+
+// SoOrderedInTime asserts that the events are in strictly increasing order
+// (no two adjacent events may share a timestamp). On failure the first
+// violating index is reported along with both timestamps.
+func (self *Fixture) SoOrderedInTime(description string, events []time.Time) {
+	self.so(description, events, shouldBeStrictlyIncreasing)
+}
+
+func shouldBeStrictlyIncreasing(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	events, ok := actual.([]time.Time)
+	if !ok {
+		return fmt.Sprintf(shouldHaveBeenTimes, actual)
+	}
+	for i := 1; i < len(events); i++ {
+		if !events[i].After(events[i-1]) {
+			return fmt.Sprintf(shouldHaveBeenStrictlyIncreasing, i,
+				i-1, events[i-1].Format(time.RFC3339Nano), i, events[i].Format(time.RFC3339Nano))
+		}
+	}
+	return success
+}
+
+// SoRingContains asserts that the contents of a bounded (ring) buffer include
+// the expected value. The caller supplies the ring's contents in logical
+// (oldest to newest) order, which is how they are reported on failure.
+func (self *Fixture) SoRingContains(description string, ring []interface{}, expected interface{}) {
+	self.so(description, ring, shouldContainInRing, expected)
+}
+
+func shouldContainInRing(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	ring, ok := actual.([]interface{})
+	if !ok {
+		return fmt.Sprintf(shouldHaveBeenARing, actual)
+	}
+	for _, item := range ring {
+		if reflect.DeepEqual(item, expected[0]) {
+			return success
+		}
+	}
+	return fmt.Sprintf(shouldHaveContainedInRing, ring, expected[0])
+}
+
+// SoEnv asserts that actual equals the value of the environment variable,
+// which is parsed into the type of actual (strings, bools, numbers, and
+// time.Durations are supported). The assertion fails if the variable is unset.
+func (self *Fixture) SoEnv(description string, actual interface{}, envVar string) {
+	self.so(description, actual, shouldEqualEnv, envVar)
+}
+
+func shouldEqualEnv(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	name := fmt.Sprint(expected[0])
+	raw, found := os.LookupEnv(name)
+	if !found {
+		return fmt.Sprintf(shouldHaveSetEnvironmentVariable, name)
+	}
+	value, err := parseAs(raw, reflect.TypeOf(actual))
+	if err != nil {
+		return fmt.Sprintf(shouldHaveParsedEnvironmentValue, name, raw, reflect.TypeOf(actual), err)
+	}
+	return assertions.ShouldEqual(actual, value)
+}
+
+func parseAs(raw string, kind reflect.Type) (interface{}, error) {
+	if kind == nil {
+		return nil, fmt.Errorf("unsupported type")
+	} else if kind == reflect.TypeOf(time.Duration(0)) {
+		return time.ParseDuration(raw)
+	}
+
+	value := reflect.New(kind).Elem()
+	switch kind.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, kind.Bits())
+		if err != nil {
+			return nil, err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, kind.Bits())
+		if err != nil {
+			return nil, err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, kind.Bits())
+		if err != nil {
+			return nil, err
+		}
+		value.SetFloat(f)
+	default:
+		return nil, fmt.Errorf("unsupported type")
+	}
+	return value.Interface(), nil
+}
+
+// SoWithinBudget runs fn once and asserts that it completed within maxTime
+// and made no more than maxAllocs heap allocations. Both measurements are
+// reported on failure.
+func (self *Fixture) SoWithinBudget(description string, fn func(), maxTime time.Duration, maxAllocs float64) {
+	self.so(description, fn, shouldBeWithinBudget, maxTime, maxAllocs)
+}
+
+func shouldBeWithinBudget(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	fn, fnOK := actual.(func())
+	maxTime, timeOK := expected[0].(time.Duration)
+	maxAllocs, allocsOK := expected[1].(float64)
+	if !fnOK || !timeOK || !allocsOK {
+		return shouldUseBudget
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	fn()
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+
+	allocs := float64(after.Mallocs - before.Mallocs)
+	if elapsed > maxTime || allocs > maxAllocs {
+		return fmt.Sprintf(shouldHaveBeenWithinBudget, maxTime, maxAllocs, elapsed, allocs)
+	}
+	return success
+}
+
+// SoChannelLen asserts that the number of values buffered (but not yet
+// received) in the channel ch equals expected.
+func (self *Fixture) SoChannelLen(description string, ch interface{}, expected int) {
+	self.so(description, ch, shouldHaveChannelLength, expected)
+}
+
+func shouldHaveChannelLength(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	ch := reflect.ValueOf(actual)
+	if ch.Kind() != reflect.Chan {
+		return fmt.Sprintf(shouldHaveBeenAChannel, actual)
+	}
+	if ch.Len() != expected[0] {
+		return fmt.Sprintf(shouldHaveHadChannelLength, expected[0], ch.Len())
+	}
+	return success
+}
+
+// SoPipeline threads input through each of the stages (in order) and asserts
+// that the final result resembles expected (see ShouldResemble). On failure
+// the value produced by each stage is reported.
+func (self *Fixture) SoPipeline(description string, input interface{}, stages []func(interface{}) interface{}, expected interface{}) {
+	self.so(description, input, shouldFlowThrough, stages, expected)
+}
+
+func shouldFlowThrough(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	stages, ok := expected[0].([]func(interface{}) interface{})
+	if !ok {
+		return fmt.Sprintf(shouldUseStages, expected[0])
+	}
+
+	value := actual
+	trace := fmt.Sprintf("\nIntermediate values:\n  input:   %#v\n", value)
+	for i, stage := range stages {
+		value = stage(value)
+		trace += fmt.Sprintf("  stage %d: %#v\n", i+1, value)
+	}
+	if fail := assertions.ShouldResemble(value, expected[1]); fail != success {
+		return fail + "\n" + trace
+	}
+	return success
+}
+
+// SoConsumesAll passes r to consume (a parser, for instance) and asserts that
+// consume returned no error and read r to completion (EOF). On failure the
+// number of bytes remaining in r is reported.
+func (self *Fixture) SoConsumesAll(description string, r io.Reader, consume func(io.Reader) error) {
+	self.so(description, r, shouldConsumeAll, consume)
+}
+
+func shouldConsumeAll(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	reader, readerOK := actual.(io.Reader)
+	consume, consumeOK := expected[0].(func(io.Reader) error)
+	if !readerOK || !consumeOK {
+		return fmt.Sprintf(shouldUseConsumer, actual, expected[0])
+	}
+	if err := consume(reader); err != nil {
+		return fmt.Sprintf(shouldHaveConsumedWithoutError, err)
+	}
+	if remaining, _ := io.Copy(ioutil.Discard, reader); remaining > 0 {
+		return fmt.Sprintf(shouldHaveConsumedAll, remaining)
+	}
+	return success
+}
+
+// SoResembleSorted sorts copies of actual and expected using less and then
+// asserts that they resemble each other (see ShouldResemble). This allows
+// order-insensitive comparison of complex elements.
+func (self *Fixture) SoResembleSorted(description string, actual, expected []interface{}, less func(a, b interface{}) bool) {
+	self.so(description, actual, shouldResembleSorted, expected, less)
+}
+
+func shouldResembleSorted(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	a, aOK := actual.([]interface{})
+	b, bOK := expected[0].([]interface{})
+	less, lessOK := expected[1].(func(a, b interface{}) bool)
+	if !aOK || !bOK || !lessOK {
+		return fmt.Sprintf(shouldUseSortable, actual, expected[0], expected[1])
+	}
+	return assertions.ShouldResemble(sortedCopy(a, less), sortedCopy(b, less))
+}
+
+func sortedCopy(items []interface{}, less func(a, b interface{}) bool) []interface{} {
+	sorted := append([]interface{}{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// StressConcurrent launches the given number of goroutines, each of which
+// calls op (with the goroutine's id) the given number of times, waits for
+// them all to finish, and asserts that none of them panicked (reporting the
+// first panic). Run it under `go test -race` to surface data races in the
+// code under test.
+func (self *Fixture) StressConcurrent(description string, goroutines int, iterations int, op func(id int)) {
+	self.so(description, op, shouldNotPanicConcurrently, goroutines, iterations)
+}
+
+func shouldNotPanicConcurrently(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	op, opOK := actual.(func(id int))
+	goroutines, goroutinesOK := expected[0].(int)
+	iterations, iterationsOK := expected[1].(int)
+	if !opOK || !goroutinesOK || !iterationsOK {
+		return fmt.Sprintf(shouldUseStress, actual, expected[0], expected[1])
+	}
+
+	var (
+		lock       sync.Mutex
+		waiter     sync.WaitGroup
+		panics     int
+		firstID    int
+		firstPanic interface{}
+	)
+	for id := 0; id < goroutines; id++ {
+		waiter.Add(1)
+		go func(id int) {
+			defer waiter.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					lock.Lock()
+					defer lock.Unlock()
+					if panics == 0 {
+						firstID, firstPanic = id, r
+					}
+					panics++
+				}
+			}()
+			for i := 0; i < iterations; i++ {
+				op(id)
+			}
+		}(id)
+	}
+	waiter.Wait()
+
+	if panics > 0 {
+		return fmt.Sprintf(shouldNotHavePanickedConcurrently, panics, firstID, firstPanic)
+	}
+	return success
+}
+
+// SoErrorChainContains asserts that err wraps every one of the sentinels
+// (see errors.Is). On failure the missing sentinels are reported.
+func (self *Fixture) SoErrorChainContains(description string, err error, sentinels ...error) {
+	expected := make([]interface{}, len(sentinels))
+	for i, sentinel := range sentinels {
+		expected[i] = sentinel
+	}
+	self.so(description, err, shouldWrapAll, expected...)
+}
+
+func shouldWrapAll(actual interface{}, expected ...interface{}) string {
+	err, _ := actual.(error)
+	if err == nil {
+		return fmt.Sprintf(shouldHaveBeenAnError, actual)
+	}
+	var missing []error
+	for _, candidate := range expected {
+		sentinel, ok := candidate.(error)
+		if !ok {
+			return fmt.Sprintf(shouldHaveBeenAnError, candidate)
+		} else if !errors.Is(err, sentinel) {
+			missing = append(missing, sentinel)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf(shouldHaveWrappedSentinels, err, missing)
+	}
+	return success
+}
+
+// SoJSONPath extracts the value at the path (like `data.items[0].id`) from
+// the JSON document and applies the assertion to it. On failure the path
+// and the document are reported. Note that JSON numbers are extracted as
+// float64 values (see encoding/json).
+func (self *Fixture) SoJSONPath(description string, jsonData []byte, path string, so func(actual interface{}, expected ...interface{}) string, expected ...interface{}) {
+	self.so(description, jsonData, shouldSatisfyJSONPath, append([]interface{}{path, so}, expected...)...)
+}
+
+func shouldSatisfyJSONPath(actual interface{}, expected ...interface{}) string {
+	if len(expected) < 2 {
+		return fmt.Sprintf(needExactValues, 2, len(expected))
+	}
+	document, documentOK := actual.([]byte)
+	path, pathOK := expected[0].(string)
+	so, soOK := expected[1].(func(actual interface{}, expected ...interface{}) string)
+	if !documentOK || !pathOK || !soOK {
+		return fmt.Sprintf(shouldUseJSONPath, actual, expected[0], expected[1])
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(document, &parsed); err != nil {
+		return fmt.Sprintf(shouldHaveBeenValidJSON, err, document)
+	}
+	value, err := extractJSONPath(parsed, path)
+	if err != nil {
+		return fmt.Sprintf(shouldHaveFoundJSONPath, path, err, document)
+	}
+	if fail := so(value, expected[2:]...); fail != success {
+		return fmt.Sprintf(shouldSatisfyJSONPathFailure, fail, path, document)
+	}
+	return success
+}
+
+// extractJSONPath navigates a document (as produced by json.Unmarshal into
+// an interface{}) along a dotted path, where each segment is a field name
+// optionally followed by any number of array indexes (like `items[0]`).
+func extractJSONPath(document interface{}, path string) (interface{}, error) {
+	value := document
+	for _, segment := range strings.Split(path, ".") {
+		name, indexes := segment, ""
+		if bracket := strings.Index(segment, "["); bracket >= 0 {
+			name, indexes = segment[:bracket], segment[bracket:]
+		}
+		if name != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'%s' is not an object", segment)
+			}
+			if value, ok = object[name]; !ok {
+				return nil, fmt.Errorf("there is no field named '%s'", name)
+			}
+		}
+		for len(indexes) > 0 {
+			end := strings.Index(indexes, "]")
+			if !strings.HasPrefix(indexes, "[") || end < 0 {
+				return nil, fmt.Errorf("'%s' is malformed", segment)
+			}
+			index, err := strconv.Atoi(indexes[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("'%s' has an invalid index", segment)
+			}
+			array, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'%s' is not an array", segment)
+			} else if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("index %d of '%s' is out of range", index, segment)
+			}
+			value, indexes = array[index], indexes[end+1:]
+		}
+	}
+	return value, nil
+}
+
+// SoMatricesClose asserts that actual and expected have the same dimensions
+// and that each element of actual is within tolerance of the corresponding
+// element of expected. On failure the first offending (row, col) is reported.
+func (self *Fixture) SoMatricesClose(description string, actual, expected [][]float64, tolerance float64) {
+	self.so(description, actual, shouldBeCloseMatrices, expected, tolerance)
+}
+
+func shouldBeCloseMatrices(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	a, aOK := actual.([][]float64)
+	b, bOK := expected[0].([][]float64)
+	tolerance, toleranceOK := expected[1].(float64)
+	if !aOK || !bOK || !toleranceOK {
+		return fmt.Sprintf(shouldUseMatrices, actual, expected[0], expected[1])
+	}
+	if len(a) != len(b) {
+		return fmt.Sprintf(shouldHaveMatchingDimensions, fmt.Sprintf("actual had %d rows and expected had %d", len(a), len(b)))
+	}
+	for row := range a {
+		if len(a[row]) != len(b[row]) {
+			return fmt.Sprintf(shouldHaveMatchingDimensions,
+				fmt.Sprintf("row %d of actual had %d columns and expected had %d", row, len(a[row]), len(b[row])))
+		}
+	}
+	for row := range a {
+		for col := range a[row] {
+			if !(math.Abs(a[row][col]-b[row][col]) <= tolerance) {
+				return fmt.Sprintf(shouldHaveBeenCloseAt, tolerance, row, col, a[row][col], b[row][col])
+			}
+		}
+	}
+	return success
+}
+
+// SoIdempotent calls fn the specified number of times and asserts that every
+// result resembles the first (see ShouldResemble). On failure the first
+// divergent call is reported.
+func (self *Fixture) SoIdempotent(description string, fn func() interface{}, times int) {
+	self.so(description, fn, shouldBeIdempotent, times)
+}
+
+func shouldBeIdempotent(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	fn, fnOK := actual.(func() interface{})
+	times, timesOK := expected[0].(int)
+	if !fnOK || !timesOK || times < 1 {
+		return fmt.Sprintf(shouldUseIdempotentFunc, actual, expected[0])
+	}
+	first := fn()
+	for call := 2; call <= times; call++ {
+		if result := fn(); assertions.ShouldResemble(result, first) != success {
+			return fmt.Sprintf(shouldHaveBeenIdempotent, call, times, first, call, result)
+		}
+	}
+	return success
+}
+
+// SoWithinStdDev asserts that value lies within n (population) standard
+// deviations of the mean of samples. On failure the z-score of value is
+// reported.
+func (self *Fixture) SoWithinStdDev(description string, samples []float64, value float64, n float64) {
+	self.so(description, value, shouldBeWithinStdDev, samples, n)
+}
+
+func shouldBeWithinStdDev(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	value, valueOK := actual.(float64)
+	samples, samplesOK := expected[0].([]float64)
+	n, nOK := expected[1].(float64)
+	if !valueOK || !samplesOK || !nOK || len(samples) == 0 {
+		return fmt.Sprintf(shouldUseSamples, actual, expected[0], expected[1])
+	}
+
+	mean := 0.0
+	for _, sample := range samples {
+		mean += sample
+	}
+	mean /= float64(len(samples))
+
+	variance := 0.0
+	for _, sample := range samples {
+		variance += (sample - mean) * (sample - mean)
+	}
+	deviation := math.Sqrt(variance / float64(len(samples)))
+
+	z := 0.0
+	if value != mean {
+		z = math.Abs(value-mean) / deviation // +Inf when all samples are equal.
+	}
+	if z > n {
+		return fmt.Sprintf(shouldHaveBeenWithinStdDev, value, n, z, mean, deviation)
+	}
+	return success
+}
+
+// SoDistinctBy computes the key of each element of slice (using keyFn) and
+// asserts that no key is repeated. On failure the duplicated key and the
+// indexes of the colliding elements are reported.
+func (self *Fixture) SoDistinctBy(description string, slice interface{}, keyFn func(interface{}) interface{}) {
+	self.so(description, slice, shouldBeDistinctBy, keyFn)
+}
+
+func shouldBeDistinctBy(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	slice := reflect.ValueOf(actual)
+	keyFn, ok := expected[0].(func(interface{}) interface{})
+	if !isSlice(slice) || !ok {
+		return fmt.Sprintf(shouldUseKeyFunc, actual, expected[0])
+	}
+
+	seen := make(map[interface{}]int)
+	for i := 0; i < slice.Len(); i++ {
+		key := keyFn(slice.Index(i).Interface())
+		index := key
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			index = fmt.Sprintf("%#v", key)
+		}
+		if first, found := seen[index]; found {
+			return fmt.Sprintf(shouldHaveBeenDistinct, key, first, i)
+		}
+		seen[index] = i
+	}
+	return success
+}
+
+// SoProtoResemble asserts that two proto-style messages (pointers to
+// generated structs, or any structs for that matter) resemble each other,
+// field by field. Only exported fields are compared so unknown fields and
+// internal state are ignored, as are fields beginning with "XXX_". Unset
+// fields (nil pointers, empty lists and maps) are considered equal to their
+// default values. On failure the paths of all differing fields are reported.
+// Reflection is used so that no proto dependency is required.
+func (self *Fixture) SoProtoResemble(description string, actual, expected interface{}) {
+	self.so(description, actual, shouldResembleMessage, expected)
+}
+
+func shouldResembleMessage(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	if actual == nil || expected[0] == nil || reflect.TypeOf(actual) != reflect.TypeOf(expected[0]) {
+		return fmt.Sprintf(shouldUseMessages, actual, expected[0])
+	}
+
+	root := reflect.TypeOf(actual)
+	for root.Kind() == reflect.Ptr {
+		root = root.Elem()
+	}
+	var differences []string
+	diffMessages(root.Name(), reflect.ValueOf(actual), reflect.ValueOf(expected[0]), &differences)
+	if len(differences) > 0 {
+		return fmt.Sprintf(shouldHaveResembledMessage, strings.Join(differences, "\n"))
+	}
+	return success
+}
+
+func diffMessages(path string, actual, expected reflect.Value, differences *[]string) {
+	actual, expected = unsetAsDefault(actual), unsetAsDefault(expected)
+
+	if actual.Kind() == reflect.Interface || expected.Kind() == reflect.Interface { // oneof fields
+		actual, expected = actual.Elem(), expected.Elem()
+		if !actual.IsValid() || !expected.IsValid() || actual.Type() != expected.Type() {
+			if isDefault(actual) && isDefault(expected) {
+				return
+			}
+			*differences = append(*differences, describeDifference(path, actual, expected))
+			return
+		}
+		diffMessages(path, actual, expected, differences)
+		return
+	}
+
+	switch actual.Kind() {
+	case reflect.Struct:
+		for i := 0; i < actual.NumField(); i++ {
+			field := actual.Type().Field(i)
+			if field.PkgPath != "" || strings.HasPrefix(field.Name, "XXX_") {
+				continue
+			}
+			diffMessages(path+"."+field.Name, actual.Field(i), expected.Field(i), differences)
+		}
+	case reflect.Slice:
+		if actual.Len() != expected.Len() {
+			*differences = append(*differences, fmt.Sprintf("  %s: expected %d elements, actual %d", path, expected.Len(), actual.Len()))
+			return
+		}
+		for i := 0; i < actual.Len(); i++ {
+			diffMessages(fmt.Sprintf("%s[%d]", path, i), actual.Index(i), expected.Index(i), differences)
+		}
+	case reflect.Map:
+		keys := sortedKeys(actual)
+		for _, key := range sortedKeys(expected) {
+			if !actual.MapIndex(key).IsValid() {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			a, e := actual.MapIndex(key), expected.MapIndex(key)
+			if !a.IsValid() {
+				a = reflect.Zero(actual.Type().Elem())
+			}
+			if !e.IsValid() {
+				e = reflect.Zero(expected.Type().Elem())
+			}
+			diffMessages(fmt.Sprintf("%s[%v]", path, key.Interface()), a, e, differences)
+		}
+	default:
+		if !reflect.DeepEqual(actual.Interface(), expected.Interface()) {
+			*differences = append(*differences, describeDifference(path, actual, expected))
+		}
+	}
+}
+
+// unsetAsDefault dereferences pointers, treating nil pointers as pointing to
+// the zero value of their type.
+func unsetAsDefault(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value = reflect.Zero(value.Type().Elem())
+		} else {
+			value = value.Elem()
+		}
+	}
+	return value
+}
+
+func isDefault(value reflect.Value) bool {
+	if !value.IsValid() {
+		return true
+	}
+	value = unsetAsDefault(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" && !isDefault(value.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return value.IsZero()
+}
+
+func describeDifference(path string, actual, expected reflect.Value) string {
+	render := func(value reflect.Value) interface{} {
+		if !value.IsValid() {
+			return nil
+		}
+		return value.Interface()
+	}
+	return fmt.Sprintf("  %s: expected '%v', actual '%v'", path, render(expected), render(actual))
+}
+
+// SoMatchesTemplate renders the text/template tmpl with data and asserts that
+// the result equals actual. On failure a line-by-line diff is reported.
+func (self *Fixture) SoMatchesTemplate(description string, actual string, tmpl string, data interface{}) {
+	self.so(description, actual, shouldMatchTemplate, tmpl, data)
+}
+
+func shouldMatchTemplate(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	text, textOK := actual.(string)
+	tmpl, tmplOK := expected[0].(string)
+	if !textOK || !tmplOK {
+		return fmt.Sprintf(shouldUseTemplate, actual, expected[0])
+	}
+	parsed, err := template.New("expected").Parse(tmpl)
+	if err != nil {
+		return fmt.Sprintf(shouldHaveRenderedTemplate, err)
+	}
+	rendered := new(strings.Builder)
+	if err := parsed.Execute(rendered, expected[1]); err != nil {
+		return fmt.Sprintf(shouldHaveRenderedTemplate, err)
+	}
+	if rendered.String() != text {
+		return fmt.Sprintf(shouldHaveMatchedTemplate, diffLines(rendered.String(), text))
+	}
+	return success
+}
+
+// diffLines lists the lines (by number) that differ between expected and actual.
+func diffLines(expected, actual string) string {
+	e, a := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	diff := new(strings.Builder)
+	for i := 0; i < len(e) || i < len(a); i++ {
+		var left, right string
+		if i < len(e) {
+			left = e[i]
+		}
+		if i < len(a) {
+			right = a[i]
+		}
+		if left == right && i < len(e) && i < len(a) {
+			continue
+		}
+		fmt.Fprintf(diff, "  line %d:\n", i+1)
+		if i < len(e) {
+			fmt.Fprintf(diff, "  - %q\n", left)
+		}
+		if i < len(a) {
+			fmt.Fprintf(diff, "  + %q\n", right)
+		}
+	}
+	return diff.String()
+}
+
+// SoRateAtMost counts the events received from ch over the specified
+// duration (or until ch is closed) and asserts that the observed rate (over
+// the whole duration) does not exceed maxPerSecond. On failure the measured
+// rate is reported.
+func (self *Fixture) SoRateAtMost(description string, ch <-chan time.Time, maxPerSecond float64, duration time.Duration) {
+	self.so(description, ch, shouldBeRateLimited, maxPerSecond, duration)
+}
+
+func shouldBeRateLimited(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	ch, chOK := actual.(<-chan time.Time)
+	limit, limitOK := expected[0].(float64)
+	duration, durationOK := expected[1].(time.Duration)
+	if !chOK || !limitOK || !durationOK || duration <= 0 {
+		return fmt.Sprintf(shouldUseRateChannel, actual, expected[0], expected[1])
+	}
+
+	events := 0
+	deadline := time.After(duration)
+observing:
+	for {
+		select {
+		case _, open := <-ch:
+			if !open {
+				break observing
+			}
+			events++
+		case <-deadline:
+			break observing
+		}
+	}
+
+	if rate := float64(events) / duration.Seconds(); rate > limit {
+		return fmt.Sprintf(shouldHaveBeenRateLimited, limit, events, duration, rate)
+	}
+	return success
+}
+
+// SoImagesClose asserts that actual and expected have the same dimensions and
+// that each channel (R, G, B, A, at 8 bits) of each pixel of actual is within
+// tolerance of expected. On failure the first differing pixel is reported.
+func (self *Fixture) SoImagesClose(description string, actual, expected image.Image, tolerance uint8) {
+	self.so(description, actual, shouldBeCloseImages, expected, tolerance)
+}
+
+func shouldBeCloseImages(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	a, aOK := actual.(image.Image)
+	b, bOK := expected[0].(image.Image)
+	tolerance, toleranceOK := expected[1].(uint8)
+	if !aOK || !bOK || !toleranceOK {
+		return fmt.Sprintf(shouldUseImages, actual, expected[0], expected[1])
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return fmt.Sprintf(shouldHaveMatchingImageSizes, ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			actualPixel := rgba8(a.At(ab.Min.X+x, ab.Min.Y+y))
+			expectedPixel := rgba8(b.At(bb.Min.X+x, bb.Min.Y+y))
+			for channel := range actualPixel {
+				if difference := int(actualPixel[channel]) - int(expectedPixel[channel]); difference > int(tolerance) || -difference > int(tolerance) {
+					return fmt.Sprintf(shouldHaveBeenCloseImages, tolerance, x, y, expectedPixel, actualPixel)
+				}
+			}
+		}
+	}
+	return success
+}
+
+// rgba8 returns the (alpha-premultiplied) channels of c at 8 bits each.
+func rgba8(c color.Color) [4]uint8 {
+	r, g, b, a := c.RGBA()
+	return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// SoConverges calls fn up to the specified number of attempts (waiting for
+// backoff between attempts) until it returns nil. If it never does, the
+// number of attempts and the last error are reported.
+func (self *Fixture) SoConverges(description string, fn func() error, attempts int, backoff time.Duration) {
+	self.so(description, fn, shouldConverge, attempts, backoff)
+}
+
+func shouldConverge(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	fn, fnOK := actual.(func() error)
+	attempts, attemptsOK := expected[0].(int)
+	backoff, backoffOK := expected[1].(time.Duration)
+	if !fnOK || !attemptsOK || !backoffOK || attempts < 1 {
+		return fmt.Sprintf(shouldUseRetries, actual, expected[0], expected[1])
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil {
+			return success
+		}
+		if attempt < attempts {
+			time.Sleep(backoff)
+		}
+	}
+	return fmt.Sprintf(shouldHaveConverged, attempts, err)
+}
+
+// SoDoesNotMutate deep-copies input, calls fn with the original, and asserts
+// that the original still resembles the copy (see ShouldResemble). On
+// failure the change is reported. (Unexported fields are copied shallowly.)
+func (self *Fixture) SoDoesNotMutate(description string, input interface{}, fn func(interface{})) {
+	self.so(description, input, shouldNotMutate, fn)
+}
+
+func shouldNotMutate(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	fn, ok := expected[0].(func(interface{}))
+	if !ok {
+		return fmt.Sprintf(shouldUseMutator, expected[0])
+	}
+	var original interface{}
+	if actual != nil {
+		original = deepCopy(reflect.ValueOf(actual)).Interface()
+	}
+	fn(actual)
+	if fail := assertions.ShouldResemble(actual, original); fail != success {
+		return fmt.Sprintf(shouldNotHaveMutated, fail)
+	}
+	return success
+}
+
+// SoDeepCopyEquals applies copyFn to value and asserts that the copy
+// resembles value (see ShouldResemble) and that it is a true deep copy:
+// every value reachable from value (through pointers, slices, and maps) is
+// then modified, which must not affect the copy. Note that value is modified
+// in the process.
+func (self *Fixture) SoDeepCopyEquals(description string, value interface{}, copyFn func(interface{}) interface{}) {
+	self.so(description, value, shouldDeepCopy, copyFn)
+}
+
+func shouldDeepCopy(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	copyFn, ok := expected[0].(func(interface{}) interface{})
+	if !ok {
+		return fmt.Sprintf(shouldUseCopyFunc, expected[0])
+	}
+	copied := copyFn(actual)
+	if fail := assertions.ShouldResemble(copied, actual); fail != success {
+		return fmt.Sprintf(shouldHaveCopied, fail)
+	}
+	if actual == nil {
+		return success
+	}
+
+	snapshot := deepCopy(reflect.ValueOf(copied)).Interface()
+	perturb(reflect.ValueOf(actual))
+	if fail := assertions.ShouldResemble(copied, snapshot); fail != success {
+		return fmt.Sprintf(shouldHaveCopiedDeeply, fail)
+	}
+	return success
+}
+
+// perturb modifies every (settable) value reachable from value.
+func perturb(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			perturb(value.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			perturb(value.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			element := reflect.New(value.Type().Elem()).Elem()
+			element.Set(value.MapIndex(key))
+			perturb(element)
+			value.SetMapIndex(key, element)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" {
+				perturb(value.Field(i))
+			}
+		}
+	}
+	if !value.CanSet() {
+		return
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(value.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		value.SetUint(value.Uint() + 1)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(value.Float() + 1)
+	case reflect.Complex64, reflect.Complex128:
+		value.SetComplex(value.Complex() + 1)
+	case reflect.String:
+		value.SetString(value.String() + "*")
+	case reflect.Bool:
+		value.SetBool(!value.Bool())
+	}
+}
+
+// deepCopy copies value, following pointers and copying the contents of
+// slices and maps.
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.New(value.Type().Elem())
+		clone.Elem().Set(deepCopy(value.Elem()))
+		return clone
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(deepCopy(value.Elem()))
+		return clone
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			clone.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return clone
+	case reflect.Array:
+		clone := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			clone.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return clone
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for _, key := range value.MapKeys() {
+			clone.SetMapIndex(key, deepCopy(value.MapIndex(key)))
+		}
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" {
+				clone.Field(i).Set(deepCopy(value.Field(i)))
+			}
+		}
+		return clone
+	}
+	return value
+}
+
+// SoHappensBefore asserts that the timestamp of event a precedes that of
+// event b. On failure both timestamps and the delta are reported.
+func (self *Fixture) SoHappensBefore(description string, events map[string]time.Time, a, b string) {
+	self.so(description, events, shouldHappenBefore, a, b)
+}
+
+func shouldHappenBefore(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	events, eventsOK := actual.(map[string]time.Time)
+	a, aOK := expected[0].(string)
+	b, bOK := expected[1].(string)
+	if !eventsOK || !aOK || !bOK {
+		return fmt.Sprintf(shouldUseNamedEvents, actual, expected[0], expected[1])
+	}
+	first, firstOK := events[a]
+	second, secondOK := events[b]
+	if !firstOK || !secondOK {
+		return fmt.Sprintf(shouldUseNamedEvents, actual, a, b)
+	}
+	if !first.Before(second) {
+		return fmt.Sprintf(shouldHaveHappenedBefore, a, b, first.Sub(second),
+			a, first.Format(time.RFC3339Nano), b, second.Format(time.RFC3339Nano))
+	}
+	return success
+}
+
+// SoJSONStable marshals value, unmarshals the result into a fresh instance
+// of the same type, marshals that, and asserts that both JSON documents are
+// byte-for-byte identical. On failure both documents are reported.
+func (self *Fixture) SoJSONStable(description string, value interface{}) {
+	self.so(description, value, shouldBeStableJSON)
+}
+
+func shouldBeStableJSON(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	first, err := json.Marshal(actual)
+	if err != nil {
+		return fmt.Sprintf(shouldHaveRoundTripped, err)
+	}
+	if actual == nil {
+		return success
+	}
+
+	kind := reflect.TypeOf(actual)
+	pointer := kind.Kind() == reflect.Ptr
+	if pointer {
+		kind = kind.Elem()
+	}
+	fresh := reflect.New(kind)
+	if err := json.Unmarshal(first, fresh.Interface()); err != nil {
+		return fmt.Sprintf(shouldHaveRoundTripped, err)
+	}
+	if !pointer {
+		fresh = fresh.Elem()
+	}
+	second, err := json.Marshal(fresh.Interface())
+	if err != nil {
+		return fmt.Sprintf(shouldHaveRoundTripped, err)
+	}
+
+	if !bytes.Equal(first, second) {
+		offset := 0
+		for offset < len(first) && offset < len(second) && first[offset] == second[offset] {
+			offset++
+		}
+		return fmt.Sprintf(shouldHaveBeenStableJSON, offset, first, second)
+	}
+	return success
+}
+
+// SoConcurrentSumEquals launches the given number of workers, each of which
+// calls increment perWorker times, waits for them all to finish, and asserts
+// that read returns expected. Run it under `go test -race` to validate
+// thread-safe accumulators (counters, concurrent maps, etc.).
+func (self *Fixture) SoConcurrentSumEquals(description string, workers int, perWorker int, increment func(), read func() int, expected int) {
+	self.so(description, increment, shouldSumConcurrently, workers, perWorker, read, expected)
+}
+
+func shouldSumConcurrently(actual interface{}, expected ...interface{}) string {
+	if fail := need(4, expected); fail != success {
+		return fail
+	}
+	increment, incrementOK := actual.(func())
+	workers, workersOK := expected[0].(int)
+	perWorker, perWorkerOK := expected[1].(int)
+	read, readOK := expected[2].(func() int)
+	total, totalOK := expected[3].(int)
+	if !incrementOK || !workersOK || !perWorkerOK || !readOK || !totalOK || workers < 1 || perWorker < 0 {
+		return fmt.Sprintf(shouldUseAccumulator, actual, expected[0], expected[1], expected[2], expected[3])
+	}
+
+	var waiter sync.WaitGroup
+	waiter.Add(workers)
+	for worker := 0; worker < workers; worker++ {
+		go func() {
+			defer waiter.Done()
+			for i := 0; i < perWorker; i++ {
+				increment()
+			}
+		}()
+	}
+	waiter.Wait()
+
+	if sum := read(); sum != total {
+		return fmt.Sprintf(shouldHaveSummedConcurrently, total, workers, perWorker, sum, sum-total)
+	}
+	return success
+}
+
+// SoParses asserts that parse accepts input (returns a nil error). On
+// failure the input and the error are reported.
+func (self *Fixture) SoParses(description string, input string, parse func(string) (interface{}, error)) {
+	self.so(description, input, shouldParse, parse)
+}
+
+// SoFailsToParse asserts that parse rejects input (returns an error). On
+// failure the input and the parsed result are reported.
+func (self *Fixture) SoFailsToParse(description string, input string, parse func(string) (interface{}, error)) {
+	self.so(description, input, shouldNotParse, parse)
+}
+
+func shouldParse(actual interface{}, expected ...interface{}) string {
+	input, parse, fail := parserOf(actual, expected)
+	if fail != success {
+		return fail
+	}
+	if _, err := parse(input); err != nil {
+		return fmt.Sprintf(shouldHaveParsed, input, err)
+	}
+	return success
+}
+
+func shouldNotParse(actual interface{}, expected ...interface{}) string {
+	input, parse, fail := parserOf(actual, expected)
+	if fail != success {
+		return fail
+	}
+	if result, err := parse(input); err == nil {
+		return fmt.Sprintf(shouldNotHaveParsed, input, result)
+	}
+	return success
+}
+
+func parserOf(actual interface{}, expected []interface{}) (string, func(string) (interface{}, error), string) {
+	if fail := need(1, expected); fail != success {
+		return "", nil, fail
+	}
+	input, inputOK := actual.(string)
+	parse, parseOK := expected[0].(func(string) (interface{}, error))
+	if !inputOK || !parseOK {
+		return "", nil, fmt.Sprintf(shouldUseParser, actual, expected[0])
+	}
+	return input, parse, success
+}
+
+// SoCounterIncreased samples the counter (via read) before and after calling
+// during and asserts that it increased by exactly the specified amount. On
+// failure the actual delta is reported.
+func (self *Fixture) SoCounterIncreased(description string, read func() int64, during func(), by int64) {
+	self.so(description, read, shouldIncreaseBy, during, by)
+}
+
+func shouldIncreaseBy(actual interface{}, expected ...interface{}) string {
+	if fail := need(2, expected); fail != success {
+		return fail
+	}
+	read, readOK := actual.(func() int64)
+	during, duringOK := expected[0].(func())
+	by, byOK := expected[1].(int64)
+	if !readOK || !duringOK || !byOK {
+		return fmt.Sprintf(shouldUseCounter, actual, expected[0], expected[1])
+	}
+	before := read()
+	during()
+	after := read()
+	if after-before != by {
+		return fmt.Sprintf(shouldHaveIncreasedBy, by, before, after, after-before)
+	}
+	return success
+}
+
+// SoNoOverlap asserts that no two of the (half-open, [start, end)) intervals
+// overlap, so intervals that merely touch are fine. On failure the first
+// overlapping pair (in order of their start) is reported.
+func (self *Fixture) SoNoOverlap(description string, intervals [][2]int64) {
+	self.so(description, intervals, shouldNotOverlap)
+}
+
+func shouldNotOverlap(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	intervals, ok := actual.([][2]int64)
+	if !ok {
+		return fmt.Sprintf(shouldUseIntervals, actual)
+	}
+	sorted := append([][2]int64(nil), intervals...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+
+	furthest := 0 // furthest is the interval (so far) that ends last.
+	for i := 1; i < len(sorted); i++ {
+		if sorted[furthest][1] > sorted[i][0] {
+			return fmt.Sprintf(shouldNotHaveOverlapped, sorted[furthest], sorted[i])
+		}
+		if sorted[i][1] > sorted[furthest][1] {
+			furthest = i
+		}
+	}
+	return success
+}
+
+// CallSequence records labeled calls (from any number of mocks and
+// goroutines) in the order they happen (see SoCallOrder).
+type CallSequence struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+// Record appends the label of a call to the sequence.
+func (self *CallSequence) Record(label string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.calls = append(self.calls, label)
+}
+
+// Calls returns the labels recorded so far, in order.
+func (self *CallSequence) Calls() []string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]string(nil), self.calls...)
+}
+
+// CallSequence returns a new, empty recorder to share among the mocks of a
+// test case.
+func (self *Fixture) CallSequence() *CallSequence {
+	return new(CallSequence)
+}
+
+// SoCallOrder asserts that the expected labels were recorded in seq in the
+// given order. Other calls may be recorded in between (that is, expected
+// must be a subsequence of the recorded calls). On failure the first label
+// not found (in order) is reported, along with all recorded calls.
+func (self *Fixture) SoCallOrder(description string, seq *CallSequence, expected ...string) {
+	self.so(description, seq, shouldHaveCallOrder, expected)
+}
+
+func shouldHaveCallOrder(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	seq, seqOK := actual.(*CallSequence)
+	labels, labelsOK := expected[0].([]string)
+	if !seqOK || seq == nil || !labelsOK {
+		return fmt.Sprintf(shouldUseCallSequence, actual)
+	}
+	calls := seq.Calls()
+	next := 0
+	for i, label := range labels {
+		for next < len(calls) && calls[next] != label {
+			next++
+		}
+		if next == len(calls) {
+			return fmt.Sprintf(shouldHaveBeenCalledInOrder, labels, label, labels[:i], calls)
+		}
+		next++
+	}
+	return success
+}
+
+// SoBackoffGrows asserts that each of the delays is strictly greater than
+// the one before it. On failure the first non-increasing pair is reported.
+func (self *Fixture) SoBackoffGrows(description string, delays []time.Duration) {
+	self.so(description, delays, shouldGrow)
+}
+
+func shouldGrow(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	delays, ok := actual.([]time.Duration)
+	if !ok {
+		return fmt.Sprintf(shouldUseDelays, actual)
+	}
+	for i := 1; i < len(delays); i++ {
+		if delays[i] <= delays[i-1] {
+			return fmt.Sprintf(shouldHaveGrown, i, delays[i], i-1, delays[i-1])
+		}
+	}
+	return success
+}
+
+// SoValidGo asserts that source is a syntactically valid Go source file (as
+// determined by go/parser). On failure the parse errors (with their
+// positions) are reported along with the offending lines.
+func (self *Fixture) SoValidGo(description string, source string) {
+	self.so(description, source, shouldBeValidGo)
+}
+
+func shouldBeValidGo(actual interface{}, expected ...interface{}) string {
+	if fail := need(0, expected); fail != success {
+		return fail
+	}
+	source, ok := actual.(string)
+	if !ok {
+		return fmt.Sprintf(shouldUseSource, actual)
+	}
+	_, err := parser.ParseFile(token.NewFileSet(), "source.go", source, parser.AllErrors)
+	if err == nil {
+		return success
+	}
+
+	errs, ok := err.(scanner.ErrorList)
+	if !ok {
+		return fmt.Sprintf(shouldHaveBeenValidGo, err)
+	}
+	lines := strings.Split(source, "\n")
+	report := new(strings.Builder)
+	for _, e := range errs {
+		fmt.Fprintln(report, e)
+		if line := e.Pos.Line; line > 0 && line <= len(lines) {
+			fmt.Fprintf(report, "  %d | %s\n", line, lines[line-1])
+		}
+	}
+	return fmt.Sprintf(shouldHaveBeenValidGo, strings.TrimSuffix(report.String(), "\n"))
+}
+
+// sortedKeys returns the keys of the map m in a stable order so that
+// failure messages are reproducible.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// SoStreamAll asserts that every item produced by next (which is called
+// until it returns false) satisfies the predicate. Items are checked as they
+// are produced so that datasets too large to hold in a slice can be checked.
+// On failure the index and value of the first offending item are reported.
+func (self *Fixture) SoStreamAll(description string, next func() (interface{}, bool), predicate func(interface{}) bool) {
+	self.so(description, next, shouldStreamAll, predicate)
+}
+
+func shouldStreamAll(actual interface{}, expected ...interface{}) string {
+	if fail := need(1, expected); fail != success {
+		return fail
+	}
+	next, nextOK := actual.(func() (interface{}, bool))
+	predicate, predicateOK := expected[0].(func(interface{}) bool)
+	if !nextOK || !predicateOK {
+		return fmt.Sprintf(shouldUseStream, actual, expected[0])
+	}
+	for index := 0; ; index++ {
+		item, more := next()
+		if !more {
+			return success
+		}
+		if !predicate(item) {
+			return fmt.Sprintf(shouldHaveSatisfiedPredicateItem, index, item)
+		}
+	}
+}
+
+// SoEventually evaluates actual (and then the assertion) every interval
+// until the assertion passes or the timeout elapses, which is handy for
+// checking on asynchronous work. On failure the result of the last attempt
+// is reported. Either way, the number of attempts made is logged.
+func (self *Fixture) SoEventually(description string, actual func() interface{}, so func(actual interface{}, expected ...interface{}) string, timeout, interval time.Duration, expected ...interface{}) {
+	attempts := 0
+	self.so(description, actual, eventually(so, timeout, interval, &attempts), expected...)
+	if attempts > 0 {
+		self.Logf("    (%d attempts)\n", attempts)
+	}
+}
+
+// eventually adapts an assertion about a value to one about a func that
+// produces the value, retrying until it passes or the timeout elapses.
+func eventually(so func(actual interface{}, expected ...interface{}) string, timeout, interval time.Duration, attempts *int) func(actual interface{}, expected ...interface{}) string {
+	return func(actual interface{}, expected ...interface{}) string {
+		produce := actual.(func() interface{})
+		deadline := time.Now().Add(timeout)
+		for {
+			*attempts++
+			result := so(produce(), expected...)
+			if result == success {
+				return success
+			}
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return fmt.Sprintf(shouldHaveEventuallyPassed, timeout, *attempts, result)
+			}
+			if remaining > interval {
+				remaining = interval
+			}
+			time.Sleep(remaining)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//go:build race
+// +build race
+
+package gounit
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// TestStressConcurrentUnsafeCounter runs a racy counter through
+// StressConcurrent in a child process (the race detector fails the whole
+// test binary) and checks that the race was detected there.
+func TestStressConcurrentUnsafeCounter(t *testing.T) {
+	if os.Getenv("GOUNIT_UNSAFE_COUNTER") == "1" {
+		counter := 0
+		f := NewFixture("A", new(spyT))
+		f.Test("B1", func() {
+			f.StressConcurrent("Unsafe counter", 8, 100, func(int) { counter++ })
+		})
+		f.Run()
+		return
+	}
+
+	command := exec.Command(os.Args[0], "-test.run=^TestStressConcurrentUnsafeCounter$")
+	command.Env = append(os.Environ(), "GOUNIT_UNSAFE_COUNTER=1")
+	output, err := command.CombinedOutput()
+
+	if ok, message := So(err, ShouldNotBeNil); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(string(output), ShouldContainSubstring, "WARNING: DATA RACE"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(string(output), ShouldContainSubstring, "shouldNotPanicConcurrently"); !ok {
+		t.Error("\n" + message)
+	}
+}
@@ -0,0 +1,1342 @@
+package gounit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestSoAllValues(t *testing.T) {
+	positive := func(v interface{}) bool { return v.(int) > 0 }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoAllValues("All values should be positive", map[string]int{"a": 1, "b": 2}, positive)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoAllValues("All values should be positive", map[string]int{"a": 1, "offender": -2}, positive)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "'offender'"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoAllKeys(t *testing.T) {
+	lower := func(k interface{}) bool { return strings.ToLower(k.(string)) == k }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoAllKeys("All keys should be lowercase", map[string]int{"a": 1, "b": 2}, lower)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoAllKeys("All keys should be lowercase", map[string]int{"a": 1, "Offender": 2}, lower)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "'Offender'"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoReadersEqual(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoReadersEqual("Identical streams", strings.NewReader("hello"), strings.NewReader("hello"))
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoReadersEqual("Differing streams", strings.NewReader("hello, world"), strings.NewReader("hello, World"))
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "offset 7"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoReadersEqual("Broken stream", iotest.ErrReader(errors.New("GOPHERS!")), strings.NewReader("hello"))
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "GOPHERS!"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoOrderedInTime(t *testing.T) {
+	now := time.Now()
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoOrderedInTime("Strictly increasing", []time.Time{now, now.Add(time.Second), now.Add(time.Minute)})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoOrderedInTime("Equal adjacent", []time.Time{now, now.Add(time.Second), now.Add(time.Second)})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "index [2]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoRingContains(t *testing.T) {
+	ring := []interface{}{"c", "d", "e"}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoRingContains("Present", ring, "d") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoRingContains("Overwritten", ring, "a") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoEnv(t *testing.T) {
+	os.Setenv("GOUNIT_TEST_EXPECTED_PORT", "8080")
+	defer os.Unsetenv("GOUNIT_TEST_EXPECTED_PORT")
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoEnv("Matches", 8080, "GOUNIT_TEST_EXPECTED_PORT") })
+	f.Test("B2", func() { f.SoEnv("Matches as a string", "8080", "GOUNIT_TEST_EXPECTED_PORT") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoEnv("Mismatch", 9090, "GOUNIT_TEST_EXPECTED_PORT") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoEnv("Unset", 8080, "GOUNIT_TEST_UNSET") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "'GOUNIT_TEST_UNSET' to be set"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+var sink []*int
+
+func TestSoWithinBudget(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoWithinBudget("Cheap and fast", func() {}, time.Second, 100) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoWithinBudget("Slow", func() { time.Sleep(time.Millisecond * 10) }, time.Millisecond, 1000)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoWithinBudget("Allocating", func() {
+			for x := 0; x < 1000; x++ {
+				sink = append(sink, new(int))
+			}
+		}, time.Second, 10)
+	})
+	f.Run()
+	sink = nil
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoChannelLen(t *testing.T) {
+	queue := make(chan int, 5)
+	queue <- 1
+	queue <- 2
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoChannelLen("Two queued", queue, 2) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoChannelLen("Three queued", queue, 3) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoPipeline(t *testing.T) {
+	double := func(v interface{}) interface{} { return v.(int) * 2 }
+	increment := func(v interface{}) interface{} { return v.(int) + 1 }
+	broken := func(v interface{}) interface{} { return v.(int) - 100 }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoPipeline("Correct", 3, []func(interface{}) interface{}{double, increment}, 7)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoPipeline("Broken", 3, []func(interface{}) interface{}{double, broken, increment}, 7)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "stage 2: -94"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoConsumesAll(t *testing.T) {
+	greedy := func(r io.Reader) error { _, err := ioutil.ReadAll(r); return err }
+	lazy := func(r io.Reader) error { _, err := r.Read(make([]byte, 4)); return err }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoConsumesAll("Greedy", strings.NewReader("hello, world"), greedy) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoConsumesAll("Lazy", strings.NewReader("hello, world"), lazy) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "8 bytes remained"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoResembleSorted(t *testing.T) {
+	type user struct {
+		ID    int
+		Roles []string
+	}
+	byID := func(a, b interface{}) bool { return a.(user).ID < b.(user).ID }
+	expected := []interface{}{user{1, []string{"admin"}}, user{2, nil}}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoResembleSorted("Reordered", []interface{}{user{2, nil}, user{1, []string{"admin"}}}, expected, byID)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoResembleSorted("Differing", []interface{}{user{2, nil}, user{1, []string{"guest"}}}, expected, byID)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestStressConcurrent(t *testing.T) {
+	var lock sync.Mutex
+	counter := 0
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.StressConcurrent("Safe counter", 8, 100, func(int) {
+			lock.Lock()
+			defer lock.Unlock()
+			counter++
+		})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(counter, ShouldEqual, 800); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.StressConcurrent("Panicking worker", 8, 100, func(id int) {
+			if id == 3 {
+				panic("GOPHERS!")
+			}
+		})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "(worker 3) with: 'GOPHERS!'"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoErrorChainContains(t *testing.T) {
+	errNotFound := errors.New("not found")
+	errStorage := errors.New("storage failure")
+	errTimeout := errors.New("timeout")
+	err := fmt.Errorf("request failed: %w", errWrapper{fmt.Errorf("loading user: %w", errStorage), errNotFound})
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoErrorChainContains("All wrapped", err, errNotFound, errStorage) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoErrorChainContains("One missing", err, errStorage, errTimeout) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "didn't wrap: [timeout]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+// errWrapper wraps an error and also matches a sentinel (see errors.Is).
+type errWrapper struct {
+	error
+	sentinel error
+}
+
+func (self errWrapper) Unwrap() error        { return self.error }
+func (self errWrapper) Is(target error) bool { return target == self.sentinel }
+
+func TestSoJSONPath(t *testing.T) {
+	document := []byte(`{"data": {"items": [{"id": 42, "name": "gopher"}, {"id": 43}]}}`)
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoJSONPath("Nested number", document, "data.items[0].id", ShouldEqual, 42.0)
+		f.SoJSONPath("Nested string", document, "data.items[0].name", ShouldEqual, "gopher")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoJSONPath("Wrong value", document, "data.items[1].id", ShouldEqual, 42.0) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Path:     data.items[1].id"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoJSONPath("Missing", document, "data.items[2].id", ShouldEqual, 42.0) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "index 2 of 'items[2]' is out of range"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoMatricesClose(t *testing.T) {
+	expected := [][]float64{{1, 2}, {3, 4}}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoMatricesClose("Close enough", [][]float64{{1.001, 2}, {3, 3.999}}, expected, 0.01) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoMatricesClose("Wrong shape", [][]float64{{1, 2}}, expected, 0.01) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "actual had 1 rows and expected had 2"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoMatricesClose("Out of tolerance", [][]float64{{1, 2}, {3.5, 4}}, expected, 0.01) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "(row 1, col 0)"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoIdempotent(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoIdempotent("Same every time", func() interface{} { return []string{"a", "b"} }, 5)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	calls := 0
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoIdempotent("Changes on the third call", func() interface{} {
+			calls++
+			return calls / 3
+		}, 5)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "call #3 of 5 diverged from call #1"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(calls, ShouldEqual, 3); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoWithinStdDev(t *testing.T) {
+	samples := []float64{2, 4, 4, 4, 5, 5, 7, 9} // mean: 5, standard deviation: 2
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoWithinStdDev("Within 2 standard deviations", samples, 8.9, 2) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoWithinStdDev("Beyond 2 standard deviations", samples, 11, 2) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "its z-score was 3.000"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoDistinctBy(t *testing.T) {
+	type record struct{ ID, Name string }
+	byID := func(value interface{}) interface{} { return value.(record).ID }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoDistinctBy("Unique IDs", []record{{"1", "a"}, {"2", "a"}, {"3", "b"}}, byID)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoDistinctBy("Colliding IDs", []record{{"1", "a"}, {"2", "b"}, {"1", "c"}}, byID)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "key '1' was shared by the elements at indexes [0] and [2]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+type protoAddress struct {
+	Street string
+	Zip    *int32
+}
+
+type protoPerson struct {
+	Name    string
+	Age     int32
+	Tags    []string
+	Labels  map[string]string
+	Address *protoAddress
+	Contact interface{} // a oneof field
+
+	XXX_unrecognized []byte
+	sizeCache        int32
+}
+
+func TestSoProtoResemble(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoProtoResemble("Equal, ignoring defaults and unknown fields",
+			&protoPerson{Name: "Gopher", Tags: []string{}, Address: &protoAddress{}, XXX_unrecognized: []byte{1}, sizeCache: 42},
+			&protoPerson{Name: "Gopher", Labels: map[string]string{}},
+		)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message + "\n" + f.output.String())
+	}
+
+	zip := int32(84101)
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoProtoResemble("Different",
+			&protoPerson{Name: "Gopher", Age: 10, Address: &protoAddress{Zip: &zip}, Labels: map[string]string{"a": "1"}},
+			&protoPerson{Name: "Gopher", Age: 11, Labels: map[string]string{"a": "2"}},
+		)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	for _, path := range []string{"protoPerson.Age: expected '11', actual '10'", "protoPerson.Address.Zip: expected '0', actual '84101'", "protoPerson.Labels[a]: expected '2', actual '1'"} {
+		if ok, message := So(f.output.String(), ShouldContainSubstring, path); !ok {
+			t.Error("\n" + message)
+		}
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "protoPerson.Name"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoMatchesTemplate(t *testing.T) {
+	const tmpl = "host: {{.Host}}\nport: {{.Port}}\n"
+	data := struct {
+		Host string
+		Port int
+	}{"localhost", 8080}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoMatchesTemplate("Matches", "host: localhost\nport: 8080\n", tmpl, data) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoMatchesTemplate("Mismatch", "host: localhost\nport: 9090\n", tmpl, data) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "line 2:\n      - \"port: 8080\"\n      + \"port: 9090\""); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "line 1:"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoRateAtMost(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		f.SoRateAtMost("Within the limit", ticker.C, 40, 200*time.Millisecond)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	events := make(chan time.Time, 100)
+	for i := 0; i < 100; i++ {
+		events <- time.Now()
+	}
+	close(events)
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoRateAtMost("Over the limit", events, 10, time.Second) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "observed 100 events in 1s, a rate of 100.00 per second"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoImagesClose(t *testing.T) {
+	solid := func(width, height int, c color.RGBA) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		return img
+	}
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	expected := solid(3, 2, gray)
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoImagesClose("Wrong size", solid(2, 2, gray), expected, 0) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "actual was 2x2 and expected was 3x2"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoImagesClose("Close enough", solid(3, 2, color.RGBA{R: 102, G: 98, B: 100, A: 255}), expected, 2)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	different := solid(3, 2, gray)
+	different.SetRGBA(2, 1, color.RGBA{R: 100, G: 110, B: 100, A: 255})
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoImagesClose("Different pixel", different, expected, 2) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "the pixel at (2, 1) differed"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Actual:   RGBA[100 110 100 255]"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoConverges(t *testing.T) {
+	calls := 0
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoConverges("Succeeds on the third try", func() error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("not yet (%d)", calls)
+			}
+			return nil
+		}, 5, time.Millisecond)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(calls, ShouldEqual, 3); !ok {
+		t.Error("\n" + message)
+	}
+
+	calls = 0
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoConverges("Never succeeds", func() error {
+			calls++
+			return fmt.Errorf("still failing (%d)", calls)
+		}, 3, time.Millisecond)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "still failed after 3 attempts"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Last error: still failing (3)"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoDoesNotMutate(t *testing.T) {
+	type config struct {
+		Name  string
+		Tags  []string
+		Limit *int
+	}
+	limit := 10
+	input := &config{Name: "A", Tags: []string{"x", "y"}, Limit: &limit}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoDoesNotMutate("Read only", input, func(value interface{}) {
+			if len(value.(*config).Tags) == 0 || *value.(*config).Limit == 0 {
+				panic("unexpected input")
+			}
+		})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoDoesNotMutate("Mutates", input, func(value interface{}) {
+			value.(*config).Tags[1] = "z"
+		})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "it was modified"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, `"z"`); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoHappensBefore(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := map[string]time.Time{
+		"request":  start,
+		"response": start.Add(250 * time.Millisecond),
+	}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoHappensBefore("Request before response", events, "request", "response") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoHappensBefore("Response before request", events, "response", "request") })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Expected 'response' to happen before 'request' (but it happened 250ms later)!"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "response: 2020-01-01T00:00:00.25Z"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+// unstableJSON marshals its entries in a different order every other time,
+// like a serializer that iterates a map directly.
+type unstableJSON struct {
+	Entries map[string]int
+	calls   *int
+}
+
+func (self unstableJSON) MarshalJSON() ([]byte, error) {
+	order := []string{"a", "b"}
+	if *self.calls%2 == 1 {
+		order = []string{"b", "a"}
+	}
+	*self.calls++
+	var fields []string
+	for _, key := range order {
+		fields = append(fields, fmt.Sprintf("%q:%d", key, self.Entries[key]))
+	}
+	return []byte("{" + strings.Join(fields, ",") + "}"), nil
+}
+
+func (self *unstableJSON) UnmarshalJSON(data []byte) error {
+	self.calls = new(int)
+	*self.calls = 1
+	return json.Unmarshal(data, &self.Entries)
+}
+
+func TestSoJSONStable(t *testing.T) {
+	type stable struct {
+		Name   string
+		Scores map[string]float64
+		Tags   []string
+	}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoJSONStable("Stable", stable{Name: "A", Scores: map[string]float64{"x": 0.1, "y": 2, "z": 1e21}, Tags: []string{"t"}})
+		f.SoJSONStable("Stable (pointer)", &stable{Name: "B"})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoJSONStable("Unstable", unstableJSON{Entries: map[string]int{"a": 1, "b": 2}, calls: new(int)})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "it differed at byte 2"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoConcurrentSumEquals(t *testing.T) {
+	var lock sync.Mutex
+	counter := 0
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoConcurrentSumEquals("Safe counter", 8, 100, func() {
+			lock.Lock()
+			defer lock.Unlock()
+			counter++
+		}, func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return counter
+		}, 800)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	// A naive read-modify-write loses updates. (It uses atomic loads and
+	// stores so that this test doesn't trip the race detector itself.)
+	var naive int64
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoConcurrentSumEquals("Naive counter", 8, 100, func() {
+			value := atomic.LoadInt64(&naive)
+			runtime.Gosched()
+			atomic.StoreInt64(&naive, value+1)
+		}, func() int { return int(atomic.LoadInt64(&naive)) }, 800)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Expected a total of '800' after 8 workers each incremented 100 times"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoParses(t *testing.T) {
+	parseInt := func(input string) (interface{}, error) { return strconv.Atoi(input) }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoParses("Valid", "42", parseInt)
+		f.SoFailsToParse("Invalid", "forty-two", parseInt)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoParses("Invalid", "forty-two", parseInt) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, `Input: "forty-two"`); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "invalid syntax"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoFailsToParse("Valid", "42", parseInt) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Result: 42"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoCounterIncreased(t *testing.T) {
+	var requests int64 = 10
+	read := func() int64 { return requests }
+	handle := func() { requests++ }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoCounterIncreased("Counts each request", read, func() { handle(); handle() }, 2)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoCounterIncreased("Off by one", read, func() { handle(); handle() }, 3)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "it went from '12' to '14', a delta of '2'"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoNoOverlap(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoNoOverlap("Back to back", [][2]int64{{20, 30}, {0, 10}, {10, 20}}) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoNoOverlap("Overlapping", [][2]int64{{40, 50}, {0, 25}, {10, 20}, {30, 45}}) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "[0 25] and [10 20] did"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoCallOrder(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		seq := f.CallSequence()
+		seq.Record("cache.Get")
+		seq.Record("db.Load")
+		seq.Record("cache.Put")
+
+		f.SoCallOrder("Exact order", seq, "cache.Get", "db.Load", "cache.Put")
+		f.SoCallOrder("Subsequence", seq, "cache.Get", "cache.Put")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		seq := f.CallSequence()
+		seq.Record("cache.Get")
+		seq.Record("db.Load")
+		seq.Record("cache.Put")
+
+		f.SoCallOrder("Wrong order", seq, "cache.Get", "cache.Put", "db.Load")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, `but 'db.Load' was not recorded after ["cache.Get" "cache.Put"]`); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoBackoffGrows(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoBackoffGrows("Exponential", []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond})
+		f.SoBackoffGrows("Linear", []time.Duration{time.Second, 2 * time.Second, 3 * time.Second})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoBackoffGrows("Flat", []time.Duration{time.Second, 2 * time.Second, 2 * time.Second})
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "delay [2] (2s) was not greater than delay [1] (2s)"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoValidGo(t *testing.T) {
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoValidGo("Valid", "package generated\n\nfunc Answer() int { return 42 }\n")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoValidGo("Syntax error", "package generated\n\nfunc Answer() int { return 42 \n")
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "source.go:3:32: expected '}', found 'EOF'"); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoDeepCopyEquals(t *testing.T) {
+	type document struct {
+		Title  string
+		Tags   []string
+		Counts map[string]int
+	}
+	original := func() *document {
+		return &document{Title: "A", Tags: []string{"x", "y"}, Counts: map[string]int{"a": 1}}
+	}
+
+	deep := func(value interface{}) interface{} {
+		source := value.(*document)
+		clone := &document{Title: source.Title, Tags: append([]string(nil), source.Tags...), Counts: map[string]int{}}
+		for key, count := range source.Counts {
+			clone.Counts[key] = count
+		}
+		return clone
+	}
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoDeepCopyEquals("Deep copy", original(), deep) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message + f.output.String())
+	}
+
+	shallow := func(value interface{}) interface{} {
+		clone := *value.(*document)
+		clone.Counts = map[string]int{"a": 1}
+		return &clone // shares Tags with the original.
+	}
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoDeepCopyEquals("Shallow copy", original(), shallow) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "it shares memory with the original"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, `x*`); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoStreamAll(t *testing.T) {
+	counter := func(limit int) func() (interface{}, bool) {
+		n := 0
+		return func() (interface{}, bool) {
+			if n == limit {
+				return nil, false
+			}
+			n++
+			return n, true
+		}
+	}
+	positive := func(item interface{}) bool { return item.(int) > 0 }
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.Test("B1", func() { f.SoStreamAll("All positive", counter(100000), positive) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+
+	pulled := 0
+	next := counter(100)
+	stream := func() (interface{}, bool) {
+		pulled++
+		return next()
+	}
+	belowFifty := func(item interface{}) bool { return item.(int) < 50 }
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() { f.SoStreamAll("Below fifty", stream, belowFifty) })
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "but item [49] didn't: '50'"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(pulled, ShouldEqual, 50); !ok {
+		t.Error("\n" + message)
+	}
+}
+
+func TestSoEventually(t *testing.T) {
+	var lock sync.Mutex
+	value := 0
+	read := func() interface{} {
+		lock.Lock()
+		defer lock.Unlock()
+		return value
+	}
+
+	spy := new(spyT)
+	f := NewFixture("A", spy)
+	f.GoTest("B1", func(done func()) {
+		go func() {
+			defer done()
+			go func() {
+				time.Sleep(time.Millisecond * 20)
+				lock.Lock()
+				defer lock.Unlock()
+				value = 42
+			}()
+			f.SoEventually("Eventually 42", read, ShouldEqual, time.Second, time.Millisecond*5, 42)
+		}()
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeFalse); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldNotContainSubstring, "    (1 attempts)\n"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, " attempts)\n"); !ok {
+		t.Error("\n" + message)
+	}
+
+	spy = new(spyT)
+	f = NewFixture("A", spy)
+	f.Test("B1", func() {
+		f.SoEventually("Never 43", read, ShouldEqual, time.Millisecond*20, time.Millisecond*10, 43)
+	})
+	f.Run()
+
+	if ok, message := So(spy.failed, ShouldBeTrue); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Expected the assertion to pass within 20ms (but it still failed after 3 attempts)!"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "Expected: '43'"); !ok {
+		t.Error("\n" + message)
+	}
+	if ok, message := So(f.output.String(), ShouldContainSubstring, "    (3 attempts)\n"); !ok {
+		t.Error("\n" + message)
+	}
+}